@@ -234,6 +234,9 @@ func resolveFilepaths(baseDir string, cfg *Config) {
 	}
 
 	clientPaths := func(scfg *HTTPClientConfig) {
+		if scfg.BasicAuth != nil {
+			scfg.BasicAuth.PasswordFile = join(scfg.BasicAuth.PasswordFile)
+		}
 		scfg.BearerTokenFile = join(scfg.BearerTokenFile)
 		scfg.TLSConfig.CAFile = join(scfg.TLSConfig.CAFile)
 		scfg.TLSConfig.CertFile = join(scfg.TLSConfig.CertFile)
@@ -267,6 +270,12 @@ func resolveFilepaths(baseDir string, cfg *Config) {
 		clientPaths(&cfg.HTTPClientConfig)
 		sdPaths(&cfg.ServiceDiscoveryConfig)
 	}
+	for _, cfg := range cfg.RemoteWriteConfigs {
+		clientPaths(&cfg.HTTPClientConfig)
+	}
+	for _, cfg := range cfg.RemoteReadConfigs {
+		clientPaths(&cfg.HTTPClientConfig)
+	}
 }
 
 func checkOverflow(m map[string]interface{}, ctx string) error {
@@ -405,9 +414,10 @@ func (c *GlobalConfig) isZero() bool {
 
 // TLSConfig configures the options for TLS connections.
 type TLSConfig struct {
-	// The CA cert to use for the targets.
+	// The CA cert to use for the targets. Reloaded when it changes.
 	CAFile string `yaml:"ca_file,omitempty"`
-	// The client cert file for the targets.
+	// The client cert file for the targets. The cert and key are reloaded
+	// when either changes.
 	CertFile string `yaml:"cert_file,omitempty"`
 	// The client key file for the targets.
 	KeyFile string `yaml:"key_file,omitempty"`
@@ -415,6 +425,11 @@ type TLSConfig struct {
 	ServerName string `yaml:"server_name,omitempty"`
 	// Disable target certificate validation.
 	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+	// The minimum TLS version, one of TLS10, TLS11, TLS12 and TLS13.
+	MinVersion string `yaml:"min_version,omitempty"`
+	// The names of the enabled cipher suites, as in crypto/tls. TLS 1.3
+	// cipher suites are not configurable.
+	CipherSuites []string `yaml:"cipher_suites,omitempty"`
 
 	// Catches all undefined fields and must be empty after parsing.
 	XXX map[string]interface{} `yaml:",inline"`
@@ -661,6 +676,9 @@ func CheckTargetAddress(address model.LabelValue) error {
 type BasicAuth struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// PasswordFile is read on every request, so that the password can be
+	// rotated without a restart.
+	PasswordFile string `yaml:"password_file,omitempty"`
 
 	// Catches all undefined fields and must be empty after parsing.
 	XXX map[string]interface{} `yaml:",inline"`
@@ -682,6 +700,9 @@ func (a *BasicAuth) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err != nil {
 		return err
 	}
+	if len(a.Password) > 0 && len(a.PasswordFile) > 0 {
+		return fmt.Errorf("at most one of basic_auth password & password_file must be configured")
+	}
 	return checkOverflow(a.XXX, "basic_auth")
 }
 
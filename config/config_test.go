@@ -69,6 +69,12 @@ var expectedConf = &Config{
 		{
 			URL:           mustParseURL("http://remote2/push"),
 			RemoteTimeout: model.Duration(30 * time.Second),
+			HTTPClientConfig: HTTPClientConfig{
+				BasicAuth: &BasicAuth{
+					Username:     "remote_user",
+					PasswordFile: "testdata/valid_password_file",
+				},
+			},
 		},
 	},
 
@@ -610,6 +616,9 @@ var expectedErrors = []struct {
 	}, {
 		filename: "bearertoken_basicauth.bad.yml",
 		errMsg:   "at most one of basic_auth, bearer_token & bearer_token_file must be configured",
+	}, {
+		filename: "basicauth_password.bad.yml",
+		errMsg:   "at most one of basic_auth password & password_file must be configured",
 	}, {
 		filename: "kubernetes_bearertoken.bad.yml",
 		errMsg:   "at most one of bearer_token & bearer_token_file must be configured",
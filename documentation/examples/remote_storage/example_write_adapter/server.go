@@ -15,11 +15,8 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
 
-	"github.com/golang/protobuf/proto"
-	"github.com/golang/snappy"
 	"github.com/prometheus/common/model"
 
 	"github.com/prometheus/prometheus/storage/remote"
@@ -27,20 +24,8 @@ import (
 
 func main() {
 	http.HandleFunc("/receive", func(w http.ResponseWriter, r *http.Request) {
-		compressed, err := ioutil.ReadAll(r.Body)
+		req, err := remote.DecodeWriteRequest(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		reqBuf, err := snappy.Decode(nil, compressed)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		var req remote.WriteRequest
-		if err := proto.Unmarshal(reqBuf, &req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
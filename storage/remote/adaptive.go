@@ -0,0 +1,94 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+const (
+	// latencyWindow is the number of most recent latencies the adaptive
+	// timeout is computed from.
+	latencyWindow = 100
+	// minLatencySamples is the number of successful requests needed before
+	// the adaptive timeout replaces the configured one.
+	minLatencySamples = 10
+	// adaptiveTimeoutFactor is the multiple of the 99th percentile latency
+	// requests are given to complete.
+	adaptiveTimeoutFactor = 3
+
+	defaultMinAdaptiveTimeout = time.Second
+)
+
+// latencyTracker keeps the latencies of the most recent successful requests,
+// and the timeouts of those timing out, which took at least as long. These
+// let the adaptive timeout grow back when the endpoint slows down.
+type latencyTracker struct {
+	mtx       sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{latencies: make([]time.Duration, 0, latencyWindow)}
+}
+
+// observe adds a latency, replacing the oldest one once the window is full.
+func (t *latencyTracker) observe(d time.Duration) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if len(t.latencies) < latencyWindow {
+		t.latencies = append(t.latencies, d)
+		return
+	}
+	t.latencies[t.next] = d
+	t.next = (t.next + 1) % latencyWindow
+}
+
+// quantile returns the q-quantile of the tracked latencies, or false if too
+// few were observed yet.
+func (t *latencyTracker) quantile(q float64) (time.Duration, bool) {
+	t.mtx.Lock()
+	sorted := make([]time.Duration, len(t.latencies))
+	copy(sorted, t.latencies)
+	t.mtx.Unlock()
+
+	if len(sorted) < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(q*float64(len(sorted)-1))], true
+}
+
+// storeTimeout returns the timeout of the next write request to u: the
+// configured timeout, unless the adaptive timeout is enabled and enough
+// requests succeeded to compute it.
+func (c *Client) storeTimeout(u *config.URL) time.Duration {
+	if c.latencies == nil {
+		return c.timeout
+	}
+	timeout := c.timeout
+	if p99, ok := c.latencies.quantile(0.99); ok {
+		timeout = adaptiveTimeoutFactor * p99
+		if timeout < c.minAdaptiveTimeout {
+			timeout = c.minAdaptiveTimeout
+		}
+	}
+	c.metrics.timeout.WithLabelValues(u.String()).Set(timeout.Seconds())
+	return timeout
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestLatencyTrackerQuantile(t *testing.T) {
+	tracker := newLatencyTracker()
+	for i := 1; i < minLatencySamples; i++ {
+		tracker.observe(time.Duration(i) * time.Millisecond)
+	}
+	if _, ok := tracker.quantile(0.99); ok {
+		t.Fatalf("Expected no quantile with %d latencies", minLatencySamples-1)
+	}
+
+	// Only the most recent latencies count once the window is full.
+	for i := 0; i < latencyWindow; i++ {
+		tracker.observe(time.Duration(i+1) * time.Second)
+	}
+	got, ok := tracker.quantile(0.99)
+	if !ok {
+		t.Fatal("Expected a quantile")
+	}
+	if want := 99 * time.Second; got != want {
+		t.Fatalf("Unexpected 99th percentile; want %v, got %v", want, got)
+	}
+	if got, _ := tracker.quantile(0); got != time.Second {
+		t.Fatalf("Unexpected minimum; want %v, got %v", time.Second, got)
+	}
+}
+
+func TestClientAdaptiveTimeout(t *testing.T) {
+	var delay int64
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Duration(atomic.LoadInt64(&delay)))
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	c, err := NewClient(0, &ClientConfig{
+		URL:                &config.URL{URL: serverURL},
+		Timeout:            model.Duration(5 * time.Second),
+		AdaptiveTimeout:    true,
+		MinAdaptiveTimeout: model.Duration(100 * time.Millisecond),
+		Registerer:         reg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := func() float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mf := range mfs {
+			if mf.GetName() == "prometheus_remote_storage_client_adaptive_timeout_seconds" {
+				return mf.Metric[0].GetGauge().GetValue()
+			}
+		}
+		t.Fatal("Adaptive timeout gauge not registered")
+		return 0
+	}
+
+	// Without latencies, the configured timeout applies.
+	if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := timeout(); got != 5 {
+		t.Fatalf("Unexpected timeout on cold start; want 5, got %v", got)
+	}
+
+	for i := 0; i < minLatencySamples; i++ {
+		if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Fast responses tighten the timeout down to its lower bound, so that
+	// slow requests time out.
+	atomic.StoreInt64(&delay, int64(500*time.Millisecond))
+	if err := c.Store(context.Background(), testWriteRequest()); err == nil {
+		t.Fatal("Expected slow request to time out")
+	}
+	if got := timeout(); got != 0.1 {
+		t.Fatalf("Unexpected adaptive timeout; want 0.1, got %v", got)
+	}
+
+	// Timeouts count as latencies, so the timeout grows until slow requests
+	// make it again.
+	for i := 0; i < 10; i++ {
+		if err = c.Store(context.Background(), testWriteRequest()); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Expected the adaptive timeout to grow, got %v", err)
+	}
+	if got := timeout(); got < 0.5 {
+		t.Fatalf("Unexpected adaptive timeout; want at least 0.5, got %v", got)
+	}
+}
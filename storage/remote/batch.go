@@ -0,0 +1,129 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+)
+
+// Defaults for batching appended series, the same as those of the queue
+// manager.
+const (
+	defaultBatchMaxSamples = 100
+	defaultBatchMaxWait    = 5 * time.Second
+)
+
+// batcher buffers the series given to Append until enough samples are
+// pending, or the oldest of them waited long enough, and then stores them
+// in a single write request. The samples appended for a series are kept in a
+// single series of the batch, so that they are sent in order.
+type batcher struct {
+	c          *Client
+	maxSamples int
+	maxWait    time.Duration
+
+	// sendMtx is held while sending, so that batches are sent in order.
+	sendMtx sync.Mutex
+
+	mtx     sync.Mutex
+	pending []*TimeSeries
+	series  map[model.Fingerprint]*TimeSeries // The pending series, by labels.
+	samples int
+	timer   *time.Timer // Running while series are pending.
+}
+
+func newBatcher(c *Client, maxSamples int, maxWait time.Duration) *batcher {
+	if maxSamples <= 0 {
+		maxSamples = defaultBatchMaxSamples
+	}
+	if maxWait <= 0 {
+		maxWait = defaultBatchMaxWait
+	}
+	return &batcher{c: c, maxSamples: maxSamples, maxWait: maxWait, series: map[model.Fingerprint]*TimeSeries{}}
+}
+
+// Append adds a series to the batch of series waiting to be stored. Once
+// BatchMaxSamples samples are pending, Append stores them before returning.
+// Otherwise, they are stored at the latest once BatchMaxWait passed since
+// the first of them was appended. Errors from storing the batches are
+// logged.
+//
+// The samples of a series are stored in the order they were appended, even
+// when sent in different batches, as batches are sent one after another.
+// The order of different series is not guaranteed.
+func (c *Client) Append(ts TimeSeries) {
+	fp := labelPairsToMetric(ts.Labels).Fingerprint()
+	b := c.batcher
+	b.mtx.Lock()
+	if prev, ok := b.series[fp]; ok {
+		prev.Samples = append(prev.Samples, ts.Samples...)
+		prev.Exemplars = append(prev.Exemplars, ts.Exemplars...)
+		prev.Histograms = append(prev.Histograms, ts.Histograms...)
+	} else {
+		// Copy the slices, as appending to the ones of the caller could
+		// overwrite their spare capacity.
+		ts.Samples = append([]*Sample(nil), ts.Samples...)
+		ts.Exemplars = append([]*Exemplar(nil), ts.Exemplars...)
+		ts.Histograms = append([]*Histogram(nil), ts.Histograms...)
+		b.series[fp] = &ts
+		b.pending = append(b.pending, &ts)
+	}
+	b.samples += len(ts.Samples) + len(ts.Histograms)
+	full := b.samples >= b.maxSamples
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.maxWait, func() { b.flushAndLog() })
+	}
+	b.mtx.Unlock()
+
+	if full {
+		b.flushAndLog()
+	}
+}
+
+// Flush stores the series appended since the last batch was stored, if any.
+func (c *Client) Flush(ctx context.Context) error {
+	return c.batcher.flush(ctx)
+}
+
+func (b *batcher) flush(ctx context.Context) error {
+	b.sendMtx.Lock()
+	defer b.sendMtx.Unlock()
+
+	b.mtx.Lock()
+	req := &WriteRequest{Timeseries: b.pending}
+	b.pending = nil
+	b.series = map[model.Fingerprint]*TimeSeries{}
+	b.samples = 0
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mtx.Unlock()
+
+	return b.c.Store(ctx, req)
+}
+
+func (b *batcher) flushAndLog() {
+	if err := b.flush(context.Background()); err != nil {
+		logger := b.c.logger
+		if b.c.url != nil {
+			logger = logger.With("url", b.c.url.String())
+		}
+		logger.Warnf("Error storing batch of appended series: %s", err)
+	}
+}
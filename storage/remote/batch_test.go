@@ -0,0 +1,173 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+)
+
+func batchTestSeries(i int) TimeSeries {
+	return TimeSeries{
+		Labels:  []*LabelPair{{Name: "__name__", Value: fmt.Sprintf("test_metric_%d", i)}},
+		Samples: []*Sample{{Value: float64(i), TimestampMs: int64(i)}},
+	}
+}
+
+func TestAppendFlushOnCount(t *testing.T) {
+	c, reqs, stop := newWriteTestClient(t, &ClientConfig{
+		BatchMaxSamples: 3,
+		BatchMaxWait:    model.Duration(time.Hour),
+	}, nil)
+	defer stop()
+
+	for i := 0; i < 7; i++ {
+		c.Append(batchTestSeries(i))
+	}
+	for i, want := range []int{3, 3} {
+		select {
+		case req := <-reqs:
+			if len(req.Timeseries) != want {
+				t.Fatalf("%d. Unexpected number of series; want %d, got %d", i, want, len(req.Timeseries))
+			}
+			if name := req.Timeseries[0].Labels[0].Value; name != fmt.Sprintf("test_metric_%d", 3*i) {
+				t.Fatalf("%d. Unexpected first series %s", i, name)
+			}
+		default:
+			t.Fatalf("%d. Expected batch to be stored by Append", i)
+		}
+	}
+	select {
+	case req := <-reqs:
+		t.Fatalf("Unexpected request before the batch is full: %v", req)
+	default:
+	}
+
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case req := <-reqs:
+		if len(req.Timeseries) != 1 {
+			t.Fatalf("Unexpected number of flushed series; want 1, got %d", len(req.Timeseries))
+		}
+	default:
+		t.Fatal("Expected Flush to store the pending series")
+	}
+
+	// Nothing is sent without pending series.
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case req := <-reqs:
+		t.Fatalf("Unexpected request flushing no series: %v", req)
+	default:
+	}
+}
+
+func TestAppendFlushOnTimer(t *testing.T) {
+	c, reqs, stop := newWriteTestClient(t, &ClientConfig{
+		BatchMaxSamples: 100,
+		BatchMaxWait:    model.Duration(50 * time.Millisecond),
+	}, nil)
+	defer stop()
+
+	begin := time.Now()
+	c.Append(batchTestSeries(0))
+	c.Append(batchTestSeries(1))
+	select {
+	case req := <-reqs:
+		if len(req.Timeseries) != 2 {
+			t.Fatalf("Unexpected number of series; want 2, got %d", len(req.Timeseries))
+		}
+		if d := time.Since(begin); d < 50*time.Millisecond {
+			t.Fatalf("Batch stored after %s, before BatchMaxWait passed", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the batch to be stored")
+	}
+
+	// The timer starts again with the next series.
+	c.Append(batchTestSeries(2))
+	select {
+	case req := <-reqs:
+		if len(req.Timeseries) != 1 {
+			t.Fatalf("Unexpected number of series; want 1, got %d", len(req.Timeseries))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the second batch to be stored")
+	}
+}
+
+func TestAppendSeriesOrder(t *testing.T) {
+	c, reqs, stop := newWriteTestClient(t, &ClientConfig{
+		BatchMaxSamples: 5,
+		BatchMaxWait:    model.Duration(time.Millisecond),
+	}, nil)
+	defer stop()
+
+	const samples = 50
+	// Check the requests while appending, as Append blocks on sending full
+	// batches.
+	errc := make(chan error, 1)
+	go func() {
+		last := map[string]int64{"a": -1, "b": -1}
+		for received := 0; received < 2*samples; {
+			select {
+			case req := <-reqs:
+				seen := map[string]bool{}
+				for _, ts := range req.Timeseries {
+					name := ts.Labels[0].Value
+					if seen[name] {
+						errc <- fmt.Errorf("series %s sent more than once in a request: %v", name, req)
+						return
+					}
+					seen[name] = true
+					for _, s := range ts.Samples {
+						if s.TimestampMs != last[name]+1 {
+							errc <- fmt.Errorf("unexpected sample of series %s; want timestamp %d, got %d", name, last[name]+1, s.TimestampMs)
+							return
+						}
+						last[name] = s.TimestampMs
+						received++
+					}
+				}
+			case <-time.After(5 * time.Second):
+				errc <- fmt.Errorf("timed out waiting for samples, got %d of %d", received, 2*samples)
+				return
+			}
+		}
+		errc <- nil
+	}()
+
+	for i := 0; i < samples; i++ {
+		for _, name := range []string{"a", "b"} {
+			c.Append(TimeSeries{
+				Labels:  []*LabelPair{{Name: "__name__", Value: name}},
+				Samples: []*Sample{{Value: float64(i), TimestampMs: int64(i)}},
+			})
+		}
+	}
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// StreamedContentType is the Content-Type of streamed read responses, which
+// consist of ChunkedReadResponse frames.
+const StreamedContentType = "application/x-streamed-protobuf; proto=remote.ChunkedReadResponse"
+
+// DefaultChunkedFrameLimit is the default maximum size of a single frame of
+// a streamed read response.
+const DefaultChunkedFrameLimit = 50 * 1024 * 1024
+
+// The table must be the same as used by readers and writers of frames on
+// the other end, which for Prometheus is always Castagnoli.
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkedWriter writes length-delimited frames, each formed by the uvarint
+// size of the data, its CRC32 Castagnoli checksum and the data itself. Every
+// frame is flushed immediately if a flusher is given.
+type ChunkedWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+
+	crc32 []byte
+}
+
+// NewChunkedWriter returns a new ChunkedWriter. The flusher may be nil.
+func NewChunkedWriter(w io.Writer, f http.Flusher) *ChunkedWriter {
+	return &ChunkedWriter{writer: w, flusher: f, crc32: make([]byte, 4)}
+}
+
+// Write writes the given bytes as a single frame.
+func (w *ChunkedWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	v := binary.PutUvarint(buf[:], uint64(len(b)))
+	if _, err := w.writer.Write(buf[:v]); err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(w.crc32, crc32.Checksum(b, castagnoliTable))
+	if _, err := w.writer.Write(w.crc32); err != nil {
+		return 0, err
+	}
+
+	n, err := w.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return n, nil
+}
+
+// ChunkedReader reads frames written by a ChunkedWriter.
+type ChunkedReader struct {
+	b         *bufio.Reader
+	data      []byte
+	sizeLimit uint64
+
+	crc32 []byte
+}
+
+// NewChunkedReader returns a new ChunkedReader which rejects frames larger
+// than sizeLimit bytes. The buffer, if given, is reused across frames.
+func NewChunkedReader(r io.Reader, sizeLimit uint64, data []byte) *ChunkedReader {
+	return &ChunkedReader{b: bufio.NewReader(r), sizeLimit: sizeLimit, data: data, crc32: make([]byte, 4)}
+}
+
+// Next returns the data of the next frame. The returned slice is only valid
+// until the next call. It returns io.EOF once there are no more frames.
+func (r *ChunkedReader) Next() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.b)
+	if err != nil {
+		return nil, err
+	}
+	if size > r.sizeLimit {
+		return nil, fmt.Errorf("chunked frame size %d exceeds limit of %d bytes", size, r.sizeLimit)
+	}
+
+	if _, err := io.ReadFull(r.b, r.crc32); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if cap(r.data) < int(size) {
+		r.data = make([]byte, size)
+	} else {
+		r.data = r.data[:size]
+	}
+	if _, err := io.ReadFull(r.b, r.data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if binary.BigEndian.Uint32(r.crc32) != crc32.Checksum(r.data, castagnoliTable) {
+		return nil, fmt.Errorf("chunked frame checksum mismatch")
+	}
+	return r.data, nil
+}
+
+// NextProto reads the next frame and unmarshals it into pb.
+func (r *ChunkedReader) NextProto(pb proto.Message) error {
+	data, err := r.Next()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, pb)
+}
+
+// unexpectedEOF turns an io.EOF in the middle of a frame into
+// io.ErrUnexpectedEOF, so that truncated streams are not mistaken for
+// complete ones.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestChunkedReaderWriter(t *testing.T) {
+	frames := [][]byte{
+		[]byte("test1"),
+		[]byte("test2"),
+		bytes.Repeat([]byte("x"), 1000),
+	}
+
+	var buf bytes.Buffer
+	w := NewChunkedWriter(&buf, nil)
+	for _, f := range frames {
+		if _, err := w.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewChunkedReader(&buf, DefaultChunkedFrameLimit, nil)
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%d. Unexpected frame; want %q, got %q", i, want, got)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestChunkedReaderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewChunkedWriter(&buf, nil).Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	corrupted := append([]byte{}, frame...)
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	tests := []struct {
+		name  string
+		data  []byte
+		limit uint64
+	}{
+		{name: "checksum mismatch", data: corrupted, limit: DefaultChunkedFrameLimit},
+		{name: "truncated frame", data: frame[:len(frame)-1], limit: DefaultChunkedFrameLimit},
+		{name: "frame over limit", data: frame, limit: 2},
+	}
+
+	for _, test := range tests {
+		r := NewChunkedReader(bytes.NewReader(test.data), test.limit, nil)
+		if _, err := r.Next(); err == nil || err == io.EOF {
+			t.Fatalf("%s: expected error, got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// CircuitBreakerConfig configures the circuit breaker of a client endpoint.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive recoverable failures
+	// after which the circuit opens.
+	FailureThreshold int
+	// Window, if positive, is the period the failures have to occur in.
+	// Failures further apart start the count anew.
+	Window model.Duration
+	// Cooldown is how long the circuit stays open before a single trial
+	// request is let through.
+	Cooldown model.Duration
+}
+
+// circuitOpenError is returned by writes while the circuit of their endpoint
+// is open.
+type circuitOpenError struct {
+	url   string
+	until time.Time
+}
+
+func (e circuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open until %s", e.url, e.until.Format(time.RFC3339))
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker sheds requests to an endpoint that keeps failing. A nil
+// circuitBreaker lets all requests through.
+type circuitBreaker struct {
+	url       string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mtx          sync.Mutex
+	state        circuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+}
+
+func newCircuitBreaker(url string, cfg *CircuitBreakerConfig) *circuitBreaker {
+	if cfg == nil || cfg.FailureThreshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		url:       url,
+		threshold: cfg.FailureThreshold,
+		window:    time.Duration(cfg.Window),
+		cooldown:  time.Duration(cfg.Cooldown),
+	}
+}
+
+// allow returns a circuitOpenError if a request must not be sent. Once the
+// cooldown has passed, it lets a single trial request through, whose outcome
+// has to be passed to record.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		until := b.openedAt.Add(b.cooldown)
+		if time.Now().Before(until) {
+			return circuitOpenError{url: b.url, until: until}
+		}
+		b.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		// A trial request is in flight.
+		return circuitOpenError{url: b.url, until: b.openedAt.Add(b.cooldown)}
+	default:
+		return nil
+	}
+}
+
+// record accounts for the outcome of a request let through by allow.
+func (b *circuitBreaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	if !failed {
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+	if b.state == circuitHalfOpen {
+		b.state = circuitOpen
+		b.openedAt = now
+		return
+	}
+
+	if b.failures == 0 || (b.window > 0 && now.Sub(b.firstFailure) > b.window) {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.state = circuitOpen
+		b.openedAt = now
+		b.failures = 0
+	}
+}
+
+// abort gives up a trial request without an outcome, such as one canceled by
+// its context, so that the next request is let through as a trial instead.
+func (b *circuitBreaker) abort() {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.state = circuitOpen
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var (
+		requests int32
+		status   int32 = http.StatusInternalServerError
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:     &config.URL{URL: serverURL},
+		Timeout: model.Duration(time.Second),
+		CircuitBreaker: &CircuitBreakerConfig{
+			FailureThreshold: 2,
+			Cooldown:         model.Duration(100 * time.Millisecond),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStore := func(step string, wantRequests int32, wantOpen, wantErr bool) {
+		err := c.Store(context.Background(), testWriteRequest())
+		if (err != nil) != wantErr {
+			t.Fatalf("%s: unexpected error: %v", step, err)
+		}
+		var openErr circuitOpenError
+		if isOpen := errors.As(err, &openErr); isOpen != wantOpen {
+			t.Fatalf("%s: unexpected circuit state; want open %v, got error %v", step, wantOpen, err)
+		}
+		if wantErr {
+			if _, ok := err.(recoverableError); !ok {
+				t.Fatalf("%s: expected recoverable error, got %v", step, err)
+			}
+		}
+		if got := atomic.LoadInt32(&requests); got != wantRequests {
+			t.Fatalf("%s: unexpected number of requests; want %d, got %d", step, wantRequests, got)
+		}
+	}
+
+	expectStore("first failure", 1, false, true)
+	expectStore("second failure", 2, false, true)
+	expectStore("open", 2, true, true)
+
+	// A failed trial request opens the circuit again.
+	time.Sleep(150 * time.Millisecond)
+	expectStore("failed trial", 3, false, true)
+	expectStore("reopened", 3, true, true)
+
+	atomic.StoreInt32(&status, http.StatusOK)
+	time.Sleep(150 * time.Millisecond)
+	expectStore("successful trial", 4, false, false)
+	expectStore("closed", 5, false, false)
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	b := newCircuitBreaker("http://localhost", &CircuitBreakerConfig{
+		FailureThreshold: 2,
+		Window:           model.Duration(50 * time.Millisecond),
+		Cooldown:         model.Duration(time.Minute),
+	})
+
+	b.record(true)
+	time.Sleep(100 * time.Millisecond)
+	b.record(true)
+	if err := b.allow(); err != nil {
+		t.Fatalf("Failures outside the window opened the circuit: %v", err)
+	}
+	b.record(true)
+	if err := b.allow(); err == nil {
+		t.Fatal("Failures within the window did not open the circuit")
+	}
+}
@@ -15,114 +15,2455 @@ package remote
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/snappy"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
 	"golang.org/x/net/context"
 	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/time/rate"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/relabel"
 	"github.com/prometheus/prometheus/storage/metric"
 	"github.com/prometheus/prometheus/util/httputil"
 )
 
-// Client allows reading and writing from/to a remote HTTP endpoint.
+// Client allows reading and writing from/to a remote HTTP endpoint. It is
+// safe for concurrent use by multiple goroutines: buffers are pooled per
+// call, and the little state shared between calls is synchronized.
 type Client struct {
-	index   int // Used to differentiate metrics.
-	url     *config.URL
-	client  *http.Client
-	timeout time.Duration
+	index          int // Used to differentiate metrics.
+	url            *config.URL
+	readURL        *config.URL
+	readChunk      time.Duration
+	failover       *failover // Nil unless several write URLs are configured.
+	metadataURL    *config.URL
+	labelNamesURL  *config.URL
+	labelValuesURL *config.URL
+	seriesURL      *config.URL
+	exemplarsURL   *config.URL
+	healthURL      *config.URL
+	client         *http.Client
+	timeout        time.Duration
+	healthTimeout  time.Duration
+	// Store calls taking longer are logged, if positive.
+	slowRequestThreshold time.Duration
+
+	// latencies is nil unless the adaptive timeout is enabled.
+	latencies          *latencyTracker
+	minAdaptiveTimeout time.Duration
+
+	compression      string
+	compressionLevel int
+	bySize           *CompressionBySize // Nil unless CompressionBySize is set.
+	acceptEncoding   string             // Of read responses, if ReadCompression is set.
+	version          string
+	downgraded       int32 // Set to 1 once falling back to protocol 1.0. Accessed atomically.
+	maxErrMsgLen     int
+	userAgent        string
+	// Set if a round tripper reads request bodies to sign them, which
+	// streamed bodies cannot be.
+	signsBodies bool
+
+	optimizeTimestamps bool
+	timestampSupport   int32 // Accessed atomically.
+
+	retryMaxAttempts int
+	retryMinBackoff  time.Duration
+	retryMaxBackoff  time.Duration
+	retryOn          map[int]struct{}           // Recoverable 4xx status codes, read-only.
+	jitter           *jitter                    // Nil unless retry jitter is enabled.
+	breakers         map[string]*circuitBreaker // By URL, read-only.
+	limiter          *rate.Limiter
+	classifyError    func(statusCode int, body []byte) bool
+	inflight         chan struct{} // Semaphore bounding Store calls, if set.
+	batcher          *batcher      // Of the series given to Append.
+
+	maxSamplesPerSend   int
+	maxSeriesPerRequest int
+	writeRelabelConfigs []*config.RelabelConfig
+	metricNamePrefix    string
+	metricNameSuffix    string
+	labelKeep           map[string]struct{} // Nil unless LabelKeep is set.
+	labelDrop           map[string]struct{}
+	sendExemplars       bool
+	dropStaleMarkers    bool
+	maxSampleAge        time.Duration
+	sampleTransformer   func(labels []*LabelPair, s *Sample)
+	dedup               *deduper // Nil unless DedupWindow is set.
+	sortSamples         bool
+	parseWriteStats     bool
+	parsePartial        bool
+	validateHistograms  bool
+	validateLabels      bool
+	utf8LabelNames      bool
+
+	onSuccess func(stats WriteStats)
+	onError   func(err error, stats WriteStats)
+
+	disableUnimplementedValues bool
+	// Set to 1 once the label values endpoint turned out to be unimplemented,
+	// if disabling it is configured. Accessed atomically.
+	labelValuesDisabled int32
+
+	dryRun   bool
+	recorder RequestRecorder
+	metrics  *clientMetrics
+	logger   log.Logger
+	tracer   opentracing.Tracer
+
+	clock       clock // Used for retries, latencies and success times.
+	idleClosers []idleConnCloser
+	closed      int32 // Set to 1 by Close. Accessed atomically.
+	lastSuccess int64 // In Unix nanoseconds. Accessed atomically.
+	// The remote write version reported by the last successful write
+	// response, a string.
+	serverVersion atomic.Value
+}
+
+// idleConnCloser is implemented by transports keeping idle connections.
+type idleConnCloser interface {
+	CloseIdleConnections()
+}
+
+// ErrClientClosed is returned by requests made through a closed Client.
+var ErrClientClosed = errors.New("remote storage client is closed")
+
+// WriteClient sends write requests to remote storage.
+type WriteClient interface {
+	Store(ctx context.Context, req *WriteRequest) error
+}
+
+// ReadClient reads samples and label values from remote storage.
+type ReadClient interface {
+	Read(ctx context.Context, query *Query) (*QueryResult, error)
+	LabelValues(ctx context.Context, name string, matchers []*LabelMatcher, startMs, endMs int64) ([]string, error)
+}
+
+var (
+	_ WriteClient = (*Client)(nil)
+	_ ReadClient  = (*Client)(nil)
+)
+
+// ClientConfig configures a Client.
+type ClientConfig struct {
+	// URL is the write endpoint. Like all endpoint URLs, it may be a
+	// unix:// URL naming a unix socket to send HTTP requests over, with the
+	// HTTP path in the "path" query parameter, as in
+	// unix:///run/receiver.sock?path=/api/v1/write.
+	URL              *config.URL
+	Timeout          model.Duration
+	HTTPClientConfig config.HTTPClientConfig
+
+	// URLs, if set instead of URL, are write endpoints, primary first, that
+	// Store fails over between on recoverable errors. It keeps writing to
+	// the last one that worked, trying the primary again once every
+	// FailoverProbeInterval, 30s by default. The primary takes the place of
+	// URL otherwise, as the default of other endpoints and in the name of
+	// the client.
+	URLs                  []*config.URL
+	FailoverProbeInterval model.Duration
+
+	// ReadURL, if set, is used for read requests instead of URL.
+	ReadURL *config.URL
+	// ReadChunkDuration, if set, makes Read split queries over longer time
+	// ranges into sequential sub-queries of at most that duration, merging
+	// their results.
+	ReadChunkDuration model.Duration
+	// MetadataURL, if set, is used by StoreMetadata instead of URL.
+	MetadataURL *config.URL
+	// LabelNamesURL is the endpoint queried by LabelNames. LabelNames is a
+	// no-op if it is not set.
+	LabelNamesURL *config.URL
+	// LabelValuesURL is the endpoint queried by LabelValues. LabelValues is
+	// a no-op if it is not set.
+	LabelValuesURL *config.URL
+	// DisableUnimplementedLabelValues makes LabelValues stop querying the
+	// endpoint once it responded with 501 Not Implemented, returning
+	// ErrNotImplemented right away instead.
+	DisableUnimplementedLabelValues bool
+	// SeriesURL is the endpoint queried by Series. Series is a no-op if it
+	// is not set.
+	SeriesURL *config.URL
+	// ExemplarsURL is the endpoint queried by Exemplars. Exemplars is a
+	// no-op if it is not set.
+	ExemplarsURL *config.URL
+	// AdaptiveTimeout makes Store derive the timeout of write requests from
+	// the latencies of recent successful ones, as three times their 99th
+	// percentile but at least MinAdaptiveTimeout. Timeout is used until
+	// enough requests succeeded.
+	AdaptiveTimeout bool
+	// MinAdaptiveTimeout is the lower bound of the adaptive timeout.
+	// Defaults to 1s.
+	MinAdaptiveTimeout model.Duration
+
+	// HealthURL, if set, is probed with HEAD requests by Healthy instead of
+	// sending empty write requests to URL.
+	HealthURL *config.URL
+	// HealthTimeout is the timeout of health checks, independent of Timeout.
+	// Defaults to 5s.
+	HealthTimeout model.Duration
+
+	// UserAgent is sent with every request. Defaults to
+	// "Prometheus/<version>".
+	UserAgent string
+
+	// MaxErrorMessageLength is the maximum number of bytes of an error
+	// response body kept in an HTTPError. Defaults to 256.
+	MaxErrorMessageLength int
+
+	// SigV4, if set, signs requests with AWS Signature Version 4, as
+	// required by Amazon Managed Service for Prometheus.
+	SigV4 *SigV4Config
+
+	// OAuth2, if set, authorizes requests with tokens obtained through the
+	// OAuth 2.0 client credentials grant.
+	OAuth2 *OAuth2Config
+
+	// HMACSecretFile, if set, is the file holding a shared secret that the
+	// body of every request is signed with, in the X-Signature header. The
+	// file is read again whenever it changes, to allow for rotation.
+	HMACSecretFile string
+
+	// WrapRoundTripper, if set, is given the transport built from the
+	// configuration and returns the one to send requests with, such as
+	// middleware wrapping it or an entirely different transport. Headers
+	// are set before requests reach it.
+	WrapRoundTripper func(http.RoundTripper) http.RoundTripper
+
+	// Headers are set on every request, such as X-Scope-OrgID to select
+	// the tenant of multi-tenant servers. Headers managed by the client
+	// itself, like Content-Encoding, are rejected.
+	Headers map[string]string
+
+	// WriteRelabelConfigs are applied to the labels of every series sent by
+	// Store. Series whose labels are dropped are not sent.
+	WriteRelabelConfigs []*config.RelabelConfig
+	// MetricNamePrefix and MetricNameSuffix are added to the metric name of
+	// every series sent by Store after relabeling, and to the metric family
+	// names of the metadata sent along, such as to tell apart the metrics
+	// of several clusters written to the same server.
+	MetricNamePrefix string
+	MetricNameSuffix string
+	// LabelKeep, if set, is the list of labels kept on every series sent by
+	// Store after relabeling, all others are removed. The labels listed in
+	// LabelDrop are removed from them in turn. The metric name label is
+	// always kept.
+	LabelKeep []string
+	LabelDrop []string
+
+	// ProtocolVersion is the remote write protocol version used by Store,
+	// either "1.0" (the default) or "2.0". Servers rejecting 2.0 with a 415
+	// response are sent 1.0 instead.
+	ProtocolVersion string
+
+	// OptimizeTimestamps makes Store send the timestamps of all samples of
+	// a series but the first relative to the first one, if the server
+	// advertises support for it. See TimestampEncodingHeader.
+	OptimizeTimestamps bool
+
+	// Compression algorithm used for write requests, one of "snappy" (the
+	// default), "zstd" or "gzip", or "none" to send them uncompressed.
+	Compression string
+	// CompressionLevel trades CPU for bandwidth with zstd, levels 1 to 22,
+	// and gzip, levels -2 (Huffman only) to 9. Zero selects the default
+	// level.
+	CompressionLevel int
+	// CompressionBySize, if set, picks the compression of every write
+	// request by its size instead. CompressionLevel then applies to the
+	// compression of large requests.
+	CompressionBySize *CompressionBySize
+	// ReadCompression lists the compression algorithms accepted for the
+	// responses to read requests and other queries, most preferred first.
+	// By default, they are expected to be snappy-compressed.
+	ReadCompression []string
+
+	// Max number of attempts Store makes on recoverable errors. Values
+	// below 2 disable retrying within the client. Timed out attempts are
+	// retried, and fail with an error wrapping context.DeadlineExceeded.
+	// Store calls canceled by the caller, or past the deadline of its
+	// context, fail with the context error.
+	RetryMaxAttempts int
+	// On recoverable errors, backoff exponentially. If set, RetryMaxBackoff
+	// also caps the delays servers ask for with Retry-After.
+	RetryMinBackoff model.Duration
+	RetryMaxBackoff model.Duration
+	// RetryOnClientErrors lists 4xx status codes to treat as recoverable,
+	// in addition to 429 and 5xx, for proxies answering with them on
+	// transient conditions.
+	RetryOnClientErrors []int
+	// ClassifyError, if set, decides whether requests failing with a non-2xx
+	// response are recoverable, given the status code and the beginning of
+	// the response body, instead of the rules above. It must be safe for
+	// concurrent use.
+	ClassifyError func(statusCode int, body []byte) (recoverable bool)
+	// RetryJitter makes Store sleep for a random duration between zero and
+	// the backoff before retrying, so that clients recovering from the same
+	// outage do not retry in lockstep. Delays asked for by servers through
+	// Retry-After are kept as is.
+	RetryJitter bool
+
+	// RateLimit, if set, caps the rate of write requests, including
+	// retries. Requests over the limit wait for their turn.
+	RateLimit *RateLimitConfig
+
+	// CircuitBreaker, if set, makes writes to an endpoint fail fast once
+	// requests to it keep failing with recoverable errors.
+	CircuitBreaker *CircuitBreakerConfig
+
+	// ProxyURL, if set, is the HTTP proxy requests are sent through, taking
+	// precedence over the proxy_url of HTTPClientConfig. If neither is set,
+	// the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	ProxyURL *config.URL
+
+	// Connection pooling of the underlying transport. Zero values keep the
+	// defaults of net/http, except for keep-alives, which stay disabled
+	// unless DisableKeepAlives is explicitly set to false.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     model.Duration
+	DisableKeepAlives   *bool
+
+	// ResponseHeaderTimeout, if positive, is how long to wait for the
+	// headers of a response once the request was sent. Unlike Timeout, it
+	// does not bound reading the response body, so that requests to a
+	// server that never replies fail fast.
+	ResponseHeaderTimeout model.Duration
+
+	// MaxRedirects is the number of 307 and 308 redirects followed per
+	// request, which keep the method and body of the request. Other
+	// redirects, and those beyond the limit, fail like other non-2xx
+	// responses. By default, no redirects are followed.
+	MaxRedirects int
+
+	// DNSCacheTTL, if positive, is how long the addresses of the hosts
+	// connected to are cached, instead of looking them up for every new
+	// connection.
+	DNSCacheTTL model.Duration
+
+	// EnableHTTP2 lets the transport negotiate HTTP/2 with TLS servers.
+	// Requests are sent over HTTP/1.1 otherwise.
+	EnableHTTP2 bool
+	// H2CPriorKnowledge makes the transport speak HTTP/2 over cleartext
+	// connections to http:// URLs without negotiation, as for servers known
+	// to support h2c. It only takes effect if EnableHTTP2 is set.
+	H2CPriorKnowledge bool
+
+	// MaxInflight, if positive, is the maximum number of concurrent Store
+	// calls. Further calls block until one completes or their context is
+	// done.
+	MaxInflight int
+
+	// MaxSamplesPerSend, if positive, is the maximum number of samples
+	// sent in one request by Store, which splits larger write requests.
+	MaxSamplesPerSend int
+
+	// MaxSeriesPerRequest, if positive, is the maximum number of series a
+	// write request given to Store may hold. Larger ones fail without being
+	// sent.
+	MaxSeriesPerRequest int
+
+	// BatchMaxSamples and BatchMaxWait control the batching of the series
+	// given to Append, which are stored once BatchMaxSamples samples are
+	// pending, 100 by default, or BatchMaxWait after the first of them
+	// was appended, 5s by default.
+	BatchMaxSamples int
+	BatchMaxWait    model.Duration
+
+	// ValidateHistograms makes Store check native histograms for
+	// consistency before sending them, failing with an error naming the
+	// offending series.
+	ValidateHistograms bool
+
+	// ValidateLabels makes Store check the labels of every series before
+	// sending them, as sent after relabeling, failing with an error naming
+	// the first offending series. Names must be valid and values non-empty
+	// UTF-8. UTF8LabelNames allows any UTF-8 in metric and label names,
+	// rather than just the legacy character set.
+	ValidateLabels bool
+	UTF8LabelNames bool
+
+	// ParseWriteStats enables parsing the rejection details of failed write
+	// requests into HTTPError.Stats, for servers that send them with the
+	// WriteStatsContentType.
+	ParseWriteStats bool
+
+	// ParsePartialSuccess makes Store parse the body of successful write
+	// responses with the WriteStatsContentType, failing with a
+	// PartialWriteError if the server rejected some of the series.
+	ParsePartialSuccess bool
+
+	// SendExemplars enables sending the exemplars of time series. They are
+	// stripped from write requests otherwise.
+	SendExemplars bool
+
+	// DropStaleMarkers makes Store drop the staleness markers Prometheus
+	// records when series disappear, for servers that cannot handle them,
+	// and series left without samples. Other NaN values are sent.
+	DropStaleMarkers bool
+
+	// MaxSampleAge, if positive, makes Store drop samples older than it,
+	// as servers would reject them anyway, and series left without
+	// samples. Dropped samples are counted in
+	// client_dropped_samples_total with the reason "too_old".
+	MaxSampleAge model.Duration
+
+	// SampleTransformer, if set, is called by Store for every sample sent,
+	// such as to convert the units of some metrics, with the labels of its
+	// series after relabeling. It may change the value and timestamp of
+	// the sample, which is a copy. Staleness markers and old samples are
+	// dropped before, if configured to.
+	SampleTransformer func(labels []*LabelPair, s *Sample)
+
+	// DedupWindow, if positive, makes Store drop samples identical in
+	// labels and timestamp to ones it sent within the window, such as
+	// those written by both replicas of an HA pair sharing the client.
+	// This is best-effort, as samples are only remembered once sent.
+	DedupWindow model.Duration
+
+	// SortSamples makes Store sort the samples of every series by
+	// timestamp before sending them, for servers rejecting out of order
+	// samples. StoreWithStats reports the number of series that were not
+	// sorted already.
+	SortSamples bool
+
+	// DryRun makes Store encode and compress write requests, and account
+	// for their size in metrics, without sending them.
+	DryRun bool
+
+	// RequestRecorder, if set, is given every write request attempt before
+	// it is sent, such as to record it for replaying later with
+	// ReplayFile.
+	RequestRecorder RequestRecorder
+
+	// Tracer records a span for every Store and every read request,
+	// propagating it to the server. Defaults to the global tracer, which
+	// does nothing unless one is registered.
+	Tracer opentracing.Tracer
+
+	// Logger is used to log requests at debug level. Defaults to the base
+	// logger.
+	Logger log.Logger
+	// SlowRequestThreshold, if positive, makes Store calls taking longer
+	// than it be logged as warnings, with the endpoint and the number of
+	// samples sent.
+	SlowRequestThreshold model.Duration
+
+	// OnSuccess and OnError, if set, are called by Store after every batch
+	// it sent, or failed to send after all retries, with the sizes of the
+	// write requests of that batch that the server accepted. They are
+	// called synchronously on the request path, so they must return
+	// quickly. Requests that fail before being sent, such as invalid ones,
+	// don't trigger them.
+	OnSuccess func(stats WriteStats)
+	OnError   func(err error, stats WriteStats)
+
+	// Registerer, if set, is used to register the client metrics. Clients
+	// sharing a registerer share the metrics, which are labelled with the
+	// endpoint URL.
+	Registerer prometheus.Registerer
+}
+
+// Label names of client metrics.
+const (
+	urlLabel         = "url"
+	operationLabel   = "operation"
+	statusClassLabel = "status_class"
+	bytesTypeLabel   = "type"
+	reasonLabel      = "reason"
+)
+
+type clientMetrics struct {
+	sentSamples    *prometheus.CounterVec
+	failedRequests *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	sentBytes      *prometheus.CounterVec
+	droppedSamples *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	inflight       *prometheus.GaugeVec
+	inflightReqs   *prometheus.GaugeVec
+	timeout        *prometheus.GaugeVec
+	lastSuccess    *prometheus.GaugeVec
+}
+
+func newClientMetrics(r prometheus.Registerer) *clientMetrics {
+	m := &clientMetrics{
+		sentSamples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_sent_samples_total",
+			Help:      "Total number of samples successfully sent by the remote storage client.",
+		},
+			[]string{urlLabel},
+		),
+		failedRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_failed_requests_total",
+			Help:      "Total number of failed remote storage requests by HTTP status class, or \"error\" if no response was received, and by reason: \"canceled\" or \"timeout\" if the request context was canceled or timed out, \"error\" for other errors and \"status\" for unsuccessful responses.",
+		},
+			[]string{urlLabel, statusClassLabel, reasonLabel},
+		),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_request_duration_seconds",
+			Help:      "Duration of remote storage requests.",
+			Buckets:   prometheus.DefBuckets,
+		},
+			[]string{urlLabel, operationLabel},
+		),
+		sentBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_sent_bytes_total",
+			Help:      "Total number of bytes of write requests sent, before and after compression.",
+		},
+			[]string{urlLabel, bytesTypeLabel},
+		),
+		droppedSamples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_dropped_samples_total",
+			Help:      "Total number of samples dropped by the remote storage client before sending, by reason.",
+		},
+			[]string{urlLabel, reasonLabel},
+		),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_retries_total",
+			Help:      "Total number of write requests retried by the remote storage client.",
+		},
+			[]string{urlLabel},
+		),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_inflight_stores",
+			Help:      "Number of Store calls of the remote storage client currently in flight.",
+		},
+			[]string{urlLabel},
+		),
+		inflightReqs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_inflight_requests",
+			Help:      "Number of HTTP requests of the remote storage client currently awaiting a response.",
+		},
+			[]string{urlLabel},
+		),
+		timeout: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_adaptive_timeout_seconds",
+			Help:      "Timeout of the last write request sent with the adaptive timeout enabled.",
+		},
+			[]string{urlLabel},
+		),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "client_last_success_timestamp_seconds",
+			Help:      "Timestamp of the last write request accepted by the remote endpoint.",
+		},
+			[]string{urlLabel},
+		),
+	}
+
+	if r != nil {
+		m.sentSamples = register(r, m.sentSamples).(*prometheus.CounterVec)
+		m.failedRequests = register(r, m.failedRequests).(*prometheus.CounterVec)
+		m.duration = register(r, m.duration).(*prometheus.HistogramVec)
+		m.sentBytes = register(r, m.sentBytes).(*prometheus.CounterVec)
+		m.droppedSamples = register(r, m.droppedSamples).(*prometheus.CounterVec)
+		m.retries = register(r, m.retries).(*prometheus.CounterVec)
+		m.inflight = register(r, m.inflight).(*prometheus.GaugeVec)
+		m.inflightReqs = register(r, m.inflightReqs).(*prometheus.GaugeVec)
+		m.timeout = register(r, m.timeout).(*prometheus.GaugeVec)
+		m.lastSuccess = register(r, m.lastSuccess).(*prometheus.GaugeVec)
+	}
+
+	return m
+}
+
+// register registers c with r. If an equal collector is registered already,
+// as happens when clients are recreated on configuration reloads, that one is
+// returned instead.
+func register(r prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := r.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// observeRequest records the duration and outcome of a request. A nil
+// response denotes that no response was received.
+func (m *clientMetrics) observeRequest(u *config.URL, operation string, duration time.Duration, resp *http.Response, err error) {
+	m.duration.WithLabelValues(u.String(), operation).Observe(duration.Seconds())
+	switch {
+	case resp == nil:
+		reason := "error"
+		switch err {
+		case context.Canceled:
+			reason = "canceled"
+		case context.DeadlineExceeded:
+			reason = "timeout"
+		}
+		m.failedRequests.WithLabelValues(u.String(), "error", reason).Inc()
+	case resp.StatusCode/100 != 2:
+		m.failedRequests.WithLabelValues(u.String(), fmt.Sprintf("%dxx", resp.StatusCode/100), "status").Inc()
+	}
+}
+
+// logRequest logs a request at debug level. A nil response denotes that no
+// response was received.
+func (c *Client) logRequest(u *config.URL, operation string, size int, begin time.Time, resp *http.Response, err error) {
+	l := c.logger.
+		With("url", u.String()).
+		With("operation", operation).
+		With("size", size).
+		With("duration", c.clock.Now().Sub(begin))
+	if resp != nil {
+		l = l.With("status", resp.StatusCode)
+	}
+	if err != nil {
+		l = l.With("err", err)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			l = l.With("body", httpErr.Body)
+		}
+	}
+	l.Debug("Remote storage request")
+}
+
+// RateLimitConfig configures a token bucket limiting the rate of requests.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained rate of requests.
+	RequestsPerSecond float64
+	// Burst is the number of requests that may be sent at once. Defaults
+	// to 1.
+	Burst int
+}
+
+func newRateLimiter(cfg *RateLimitConfig) *rate.Limiter {
+	if cfg == nil || cfg.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), burst)
+}
+
+// NewClient creates a new Client.
+func NewClient(index int, conf *ClientConfig) (*Client, error) {
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	compression := conf.Compression
+	if compression == "" {
+		compression = SnappyCompression
+	}
+	var bySize *CompressionBySize
+	if conf.CompressionBySize != nil {
+		bySize = &CompressionBySize{
+			Threshold: conf.CompressionBySize.Threshold,
+			Small:     conf.CompressionBySize.Small,
+			Large:     conf.CompressionBySize.Large,
+		}
+		if bySize.Small == "" {
+			bySize.Small = SnappyCompression
+		}
+		if bySize.Large == "" {
+			bySize.Large = ZstdCompression
+		}
+	}
+	version := conf.ProtocolVersion
+	if version == "" {
+		version = ProtocolVersion1
+	}
+
+	hc := conf.HTTPClientConfig
+	var (
+		http2Err    error
+		idleClosers []idleConnCloser
+	)
+	opts := []httputil.TransportOption{conf.proxyOption, conf.poolingOption}
+	if conf.DNSCacheTTL > 0 {
+		opts = append(opts, newDNSCache(time.Duration(conf.DNSCacheTTL)).option)
+	}
+	for _, u := range conf.urls() {
+		if u.Scheme == "unix" {
+			opts = append(opts, func(t *http.Transport) {
+				unix := newUnixRoundTripper(t)
+				t.RegisterProtocol("unix", unix)
+				idleClosers = append(idleClosers, unix)
+			})
+			break
+		}
+	}
+	if conf.EnableHTTP2 {
+		opts = append(opts, func(t *http.Transport) {
+			var h2c *http2.Transport
+			h2c, http2Err = configureHTTP2(t, conf.H2CPriorKnowledge)
+			if h2c != nil {
+				idleClosers = append(idleClosers, h2c)
+			}
+		})
+	}
+	opts = append(opts, func(t *http.Transport) {
+		idleClosers = append(idleClosers, t)
+	})
+	httpClient, err := httputil.NewClientFromConfig(hc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.CheckRedirect = checkRedirect(conf.MaxRedirects)
+	if http2Err != nil {
+		return nil, fmt.Errorf("could not configure HTTP/2: %s", http2Err)
+	}
+	if conf.SigV4 != nil {
+		httpClient.Transport, err = newSigV4RoundTripper(conf.SigV4, httpClient.Transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.OAuth2 != nil {
+		httpClient.Transport, err = newOAuth2RoundTripper(conf.OAuth2, httpClient.Transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.HMACSecretFile != "" {
+		httpClient.Transport, err = newHMACRoundTripper(conf.HMACSecretFile, httpClient.Transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.WrapRoundTripper != nil {
+		httpClient.Transport = conf.WrapRoundTripper(httpClient.Transport)
+	}
+	if len(conf.Headers) > 0 {
+		// Set the headers first, so that they are covered by signatures.
+		httpClient.Transport = newHeadersRoundTripper(conf.Headers, httpClient.Transport)
+	}
+
+	errMsgLen := conf.MaxErrorMessageLength
+	if errMsgLen <= 0 {
+		errMsgLen = maxErrMsgLen
+	}
+
+	readURL := conf.ReadURL
+	if readURL == nil {
+		readURL = conf.writeURL()
+	}
+	logger := conf.Logger
+	if logger == nil {
+		logger = log.Base()
+	}
+	tracer := conf.Tracer
+	if tracer == nil {
+		tracer = opentracing.GlobalTracer()
+	}
+
+	userAgent := conf.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	healthTimeout := time.Duration(conf.HealthTimeout)
+	if healthTimeout <= 0 {
+		healthTimeout = defaultHealthTimeout
+	}
+
+	retryOn := make(map[int]struct{}, len(conf.RetryOnClientErrors))
+	for _, code := range conf.RetryOnClientErrors {
+		retryOn[code] = struct{}{}
+	}
+
+	var latencies *latencyTracker
+	minAdaptiveTimeout := time.Duration(conf.MinAdaptiveTimeout)
+	if conf.AdaptiveTimeout {
+		latencies = newLatencyTracker()
+		if minAdaptiveTimeout == 0 {
+			minAdaptiveTimeout = defaultMinAdaptiveTimeout
+		}
+	}
+
+	metadataURL := conf.MetadataURL
+	if metadataURL == nil {
+		metadataURL = conf.writeURL()
+	}
+
+	var failover *failover
+	if len(conf.URLs) > 1 {
+		failover = newFailover(conf.URLs, time.Duration(conf.FailoverProbeInterval))
+	}
+
+	var inflight chan struct{}
+	if conf.MaxInflight > 0 {
+		inflight = make(chan struct{}, conf.MaxInflight)
+	}
+
+	breakers := map[string]*circuitBreaker{}
+	for _, u := range append([]*config.URL{conf.writeURL(), metadataURL}, conf.URLs...) {
+		if u != nil {
+			breakers[u.String()] = newCircuitBreaker(u.String(), conf.CircuitBreaker)
+		}
+	}
+
+	c := &Client{
+		index:          index,
+		url:            conf.writeURL(),
+		readURL:        readURL,
+		readChunk:      time.Duration(conf.ReadChunkDuration),
+		failover:       failover,
+		metadataURL:    metadataURL,
+		labelNamesURL:  conf.LabelNamesURL,
+		labelValuesURL: conf.LabelValuesURL,
+		seriesURL:      conf.SeriesURL,
+		exemplarsURL:   conf.ExemplarsURL,
+		healthURL:      conf.HealthURL,
+		healthTimeout:  healthTimeout,
+		client:         httpClient,
+		timeout:        time.Duration(conf.Timeout),
+
+		slowRequestThreshold: time.Duration(conf.SlowRequestThreshold),
+
+		compression:      compression,
+		compressionLevel: conf.CompressionLevel,
+		bySize:           bySize,
+		acceptEncoding:   strings.Join(conf.ReadCompression, ", "),
+		version:          version,
+		maxErrMsgLen:     errMsgLen,
+		userAgent:        userAgent,
+		signsBodies:      conf.SigV4 != nil || conf.HMACSecretFile != "",
+
+		latencies:          latencies,
+		minAdaptiveTimeout: minAdaptiveTimeout,
+
+		optimizeTimestamps: conf.OptimizeTimestamps,
+
+		retryMaxAttempts: conf.RetryMaxAttempts,
+		retryMinBackoff:  time.Duration(conf.RetryMinBackoff),
+		retryMaxBackoff:  time.Duration(conf.RetryMaxBackoff),
+		retryOn:          retryOn,
+		classifyError:    conf.ClassifyError,
+		jitter:           newJitter(conf.RetryJitter),
+		breakers:         breakers,
+		limiter:          newRateLimiter(conf.RateLimit),
+		inflight:         inflight,
+
+		maxSamplesPerSend:   conf.MaxSamplesPerSend,
+		maxSeriesPerRequest: conf.MaxSeriesPerRequest,
+		writeRelabelConfigs: conf.WriteRelabelConfigs,
+		metricNamePrefix:    conf.MetricNamePrefix,
+		metricNameSuffix:    conf.MetricNameSuffix,
+		labelKeep:           labelSet(conf.LabelKeep),
+		labelDrop:           labelSet(conf.LabelDrop),
+		sendExemplars:       conf.SendExemplars,
+		dropStaleMarkers:    conf.DropStaleMarkers,
+		maxSampleAge:        time.Duration(conf.MaxSampleAge),
+		sampleTransformer:   conf.SampleTransformer,
+		dedup:               newDeduper(time.Duration(conf.DedupWindow)),
+		sortSamples:         conf.SortSamples,
+		validateHistograms:  conf.ValidateHistograms,
+		validateLabels:      conf.ValidateLabels,
+		utf8LabelNames:      conf.UTF8LabelNames,
+		parseWriteStats:     conf.ParseWriteStats,
+		parsePartial:        conf.ParsePartialSuccess,
+
+		onSuccess: conf.OnSuccess,
+		onError:   conf.OnError,
+
+		disableUnimplementedValues: conf.DisableUnimplementedLabelValues,
+
+		dryRun:   conf.DryRun,
+		recorder: conf.RequestRecorder,
+		metrics:  newClientMetrics(conf.Registerer),
+		logger:   logger,
+		tracer:   tracer,
+
+		clock:       realClock{},
+		idleClosers: idleClosers,
+	}
+	c.batcher = newBatcher(c, conf.BatchMaxSamples, time.Duration(conf.BatchMaxWait))
+	return c, nil
+}
+
+// Clone returns a deep copy of the configuration, so that it can be changed,
+// such as on reloads, while clients built from the original are in use.
+// Functions and interfaces, such as hooks, loggers and registerers, are
+// shared with the original.
+func (conf *ClientConfig) Clone() *ClientConfig {
+	cp := *conf
+
+	cp.URL = cloneURL(conf.URL)
+	cp.HTTPClientConfig = cloneHTTPClientConfig(conf.HTTPClientConfig)
+	if conf.URLs != nil {
+		cp.URLs = make([]*config.URL, len(conf.URLs))
+		for i, u := range conf.URLs {
+			cp.URLs[i] = cloneURL(u)
+		}
+	}
+	cp.ReadURL = cloneURL(conf.ReadURL)
+	cp.MetadataURL = cloneURL(conf.MetadataURL)
+	cp.LabelNamesURL = cloneURL(conf.LabelNamesURL)
+	cp.LabelValuesURL = cloneURL(conf.LabelValuesURL)
+	cp.SeriesURL = cloneURL(conf.SeriesURL)
+	cp.ExemplarsURL = cloneURL(conf.ExemplarsURL)
+	cp.HealthURL = cloneURL(conf.HealthURL)
+	cp.ProxyURL = cloneURL(conf.ProxyURL)
+
+	if conf.SigV4 != nil {
+		sigV4 := *conf.SigV4
+		cp.SigV4 = &sigV4
+	}
+	if conf.OAuth2 != nil {
+		oauth2 := *conf.OAuth2
+		oauth2.Scopes = cloneStrings(conf.OAuth2.Scopes)
+		oauth2.EndpointParams = cloneStringMap(conf.OAuth2.EndpointParams)
+		cp.OAuth2 = &oauth2
+	}
+	cp.Headers = cloneStringMap(conf.Headers)
+
+	if conf.WriteRelabelConfigs != nil {
+		cp.WriteRelabelConfigs = make([]*config.RelabelConfig, len(conf.WriteRelabelConfigs))
+		for i, rc := range conf.WriteRelabelConfigs {
+			if rc == nil {
+				continue
+			}
+			// Compiled regular expressions are safe to share.
+			rcp := *rc
+			if rc.SourceLabels != nil {
+				rcp.SourceLabels = append(make(model.LabelNames, 0, len(rc.SourceLabels)), rc.SourceLabels...)
+			}
+			rcp.XXX = cloneXXX(rc.XXX)
+			cp.WriteRelabelConfigs[i] = &rcp
+		}
+	}
+	cp.LabelKeep = cloneStrings(conf.LabelKeep)
+	cp.LabelDrop = cloneStrings(conf.LabelDrop)
+
+	if conf.CompressionBySize != nil {
+		bySize := *conf.CompressionBySize
+		cp.CompressionBySize = &bySize
+	}
+	cp.ReadCompression = cloneStrings(conf.ReadCompression)
+	if conf.RetryOnClientErrors != nil {
+		cp.RetryOnClientErrors = append(make([]int, 0, len(conf.RetryOnClientErrors)), conf.RetryOnClientErrors...)
+	}
+	if conf.RateLimit != nil {
+		rateLimit := *conf.RateLimit
+		cp.RateLimit = &rateLimit
+	}
+	if conf.CircuitBreaker != nil {
+		breaker := *conf.CircuitBreaker
+		cp.CircuitBreaker = &breaker
+	}
+	if conf.DisableKeepAlives != nil {
+		disable := *conf.DisableKeepAlives
+		cp.DisableKeepAlives = &disable
+	}
+	return &cp
+}
+
+func cloneURL(u *config.URL) *config.URL {
+	if u == nil {
+		return nil
+	}
+	cp := config.URL{}
+	if u.URL != nil {
+		// Userinfo is immutable, and safe to share.
+		uc := *u.URL
+		cp.URL = &uc
+	}
+	return &cp
+}
+
+func cloneHTTPClientConfig(hc config.HTTPClientConfig) config.HTTPClientConfig {
+	cp := hc
+	if hc.BasicAuth != nil {
+		basicAuth := *hc.BasicAuth
+		basicAuth.XXX = cloneXXX(hc.BasicAuth.XXX)
+		cp.BasicAuth = &basicAuth
+	}
+	cp.ProxyURL = *cloneURL(&hc.ProxyURL)
+	cp.TLSConfig.CipherSuites = cloneStrings(hc.TLSConfig.CipherSuites)
+	cp.TLSConfig.XXX = cloneXXX(hc.TLSConfig.XXX)
+	cp.XXX = cloneXXX(hc.XXX)
+	return cp
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
+
+func cloneStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+// cloneXXX copies the map of undefined fields of a parsed configuration,
+// which is empty once validated.
+func cloneXXX(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+// validate returns an error naming the offending field if the configuration
+// is invalid.
+func (conf *ClientConfig) validate() error {
+	var hasURL bool
+	for _, u := range conf.urls() {
+		hasURL = true
+		if u.Scheme == "unix" && u.Path == "" {
+			return fmt.Errorf("no socket path in unix URL %s", u)
+		}
+	}
+	if !hasURL {
+		return fmt.Errorf("no endpoint configured, at least one of URL, URLs, ReadURL, MetadataURL, LabelNamesURL, LabelValuesURL, SeriesURL, ExemplarsURL & HealthURL must be set")
+	}
+	if conf.URL != nil && len(conf.URLs) > 0 {
+		return fmt.Errorf("at most one of URL & URLs must be configured")
+	}
+	for i, u := range conf.URLs {
+		if u == nil || u.URL == nil {
+			return fmt.Errorf("URLs[%d] must not be empty", i)
+		}
+	}
+
+	for _, d := range []struct {
+		name  string
+		value model.Duration
+	}{
+		{"Timeout", conf.Timeout},
+		{"HealthTimeout", conf.HealthTimeout},
+		{"ReadChunkDuration", conf.ReadChunkDuration},
+		{"MinAdaptiveTimeout", conf.MinAdaptiveTimeout},
+		{"RetryMinBackoff", conf.RetryMinBackoff},
+		{"RetryMaxBackoff", conf.RetryMaxBackoff},
+		{"IdleConnTimeout", conf.IdleConnTimeout},
+		{"ResponseHeaderTimeout", conf.ResponseHeaderTimeout},
+		{"DNSCacheTTL", conf.DNSCacheTTL},
+		{"FailoverProbeInterval", conf.FailoverProbeInterval},
+		{"BatchMaxWait", conf.BatchMaxWait},
+		{"DedupWindow", conf.DedupWindow},
+		{"SlowRequestThreshold", conf.SlowRequestThreshold},
+	} {
+		if d.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %s", d.name, time.Duration(d.value))
+		}
+	}
+	if conf.MaxRedirects < 0 {
+		return fmt.Errorf("MaxRedirects must not be negative, got %d", conf.MaxRedirects)
+	}
+
+	hc := conf.HTTPClientConfig
+	if len(hc.BearerToken) > 0 && len(hc.BearerTokenFile) > 0 {
+		return fmt.Errorf("at most one of bearer_token & bearer_token_file must be configured")
+	}
+	if hc.BasicAuth != nil && len(hc.BasicAuth.Password) > 0 && len(hc.BasicAuth.PasswordFile) > 0 {
+		return fmt.Errorf("at most one of basic_auth password & password_file must be configured")
+	}
+	var auths []string
+	for _, auth := range []struct {
+		name       string
+		configured bool
+	}{
+		{"basic_auth", hc.BasicAuth != nil},
+		{"bearer_token", len(hc.BearerToken) > 0},
+		{"bearer_token_file", len(hc.BearerTokenFile) > 0},
+		{"sigv4", conf.SigV4 != nil},
+		{"oauth2", conf.OAuth2 != nil},
+	} {
+		if auth.configured {
+			auths = append(auths, auth.name)
+		}
+	}
+	if len(auths) > 1 {
+		return fmt.Errorf("at most one of basic_auth, bearer_token, bearer_token_file, sigv4 & oauth2 must be configured, got %s", strings.Join(auths, " & "))
+	}
+
+	if err := validateCompression(conf.Compression); err != nil {
+		return err
+	}
+	if b := conf.CompressionBySize; b != nil {
+		if b.Threshold <= 0 {
+			return fmt.Errorf("compression by size threshold must be positive, got %d", b.Threshold)
+		}
+		for _, compression := range []string{b.Small, b.Large} {
+			if err := validateCompression(compression); err != nil {
+				return err
+			}
+		}
+		large := b.Large
+		if large == "" {
+			large = ZstdCompression
+		}
+		if err := validateCompressionLevel(large, conf.CompressionLevel); err != nil {
+			return err
+		}
+	} else if err := validateCompressionLevel(conf.Compression, conf.CompressionLevel); err != nil {
+		return err
+	}
+	for _, compression := range conf.ReadCompression {
+		if compression == "" || compression == NoCompression || validateCompression(compression) != nil {
+			return fmt.Errorf("unsupported read compression %q", compression)
+		}
+	}
+	if err := validateProtocolVersion(conf.ProtocolVersion); err != nil {
+		return err
+	}
+	for _, code := range conf.RetryOnClientErrors {
+		if code/100 != 4 {
+			return fmt.Errorf("RetryOnClientErrors must only list 4xx status codes, got %d", code)
+		}
+	}
+	return validateHeaders(conf.Headers)
+}
+
+// urls returns the endpoint URLs that are set.
+func (conf *ClientConfig) urls() []*url.URL {
+	var urls []*url.URL
+	for _, u := range append([]*config.URL{
+		conf.URL, conf.ReadURL, conf.MetadataURL, conf.LabelNamesURL,
+		conf.LabelValuesURL, conf.SeriesURL, conf.ExemplarsURL, conf.HealthURL,
+	}, conf.URLs...) {
+		if u != nil && u.URL != nil {
+			urls = append(urls, u.URL)
+		}
+	}
+	return urls
+}
+
+// writeURL returns the write endpoint, the primary one if URLs is set.
+func (conf *ClientConfig) writeURL() *config.URL {
+	if conf.URL == nil && len(conf.URLs) > 0 {
+		return conf.URLs[0]
+	}
+	return conf.URL
+}
+
+// proxyOption sets the proxy of a transport.
+func (conf *ClientConfig) proxyOption(t *http.Transport) {
+	switch {
+	case conf.ProxyURL != nil && conf.ProxyURL.URL != nil:
+		t.Proxy = http.ProxyURL(conf.ProxyURL.URL)
+	case conf.HTTPClientConfig.ProxyURL.URL == nil:
+		t.Proxy = http.ProxyFromEnvironment
+	}
+}
+
+// poolingOption applies the connection pooling and response header timeout
+// settings to a transport.
+func (conf *ClientConfig) poolingOption(t *http.Transport) {
+	if conf.MaxIdleConns > 0 {
+		t.MaxIdleConns = conf.MaxIdleConns
+	}
+	if conf.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	}
+	if conf.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = time.Duration(conf.IdleConnTimeout)
+	}
+	if conf.DisableKeepAlives != nil {
+		t.DisableKeepAlives = *conf.DisableKeepAlives
+	}
+	if conf.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = time.Duration(conf.ResponseHeaderTimeout)
+	}
+}
+
+// configureHTTP2 enables HTTP/2 on a transport. With h2c, requests to
+// http:// URLs are passed to an HTTP/2 transport dialing plain TCP, which
+// keeps its own connections, so that all requests to a host are multiplexed
+// over a single one. That transport is returned, if any.
+func configureHTTP2(t *http.Transport, h2c bool) (*http2.Transport, error) {
+	if err := http2.ConfigureTransport(t); err != nil {
+		return nil, err
+	}
+	if !h2c {
+		return nil, nil
+	}
+	dial := dialFunc(t)
+	h2cTransport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(network, addr)
+		},
+	}
+	if err := registerProtocol(t, "http", h2cTransport); err != nil {
+		return nil, err
+	}
+	return h2cTransport, nil
+}
+
+// registerProtocol calls Transport.RegisterProtocol, turning its panic on
+// duplicate registration into an error.
+func registerProtocol(t *http.Transport, scheme string, rt http.RoundTripper) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	t.RegisterProtocol(scheme, rt)
+	return nil
+}
+
+var defaultUserAgent = fmt.Sprintf("Prometheus/%s", version.Version)
+
+// errNoWriteURL is returned by writes through a client configured without a
+// URL to write to.
+var errNoWriteURL = errors.New("no write URL configured")
+
+// ErrNotImplemented is returned for requests the remote endpoint does not
+// implement, as indicated by a 501 Not Implemented response.
+var ErrNotImplemented = errors.New("remote endpoint does not implement the request")
+
+// maxErrMsgLen is the default maximum number of bytes of a response body kept
+// in an HTTPError.
+const maxErrMsgLen = 256
+
+type recoverableError struct {
+	error
+}
+
+// Unwrap returns the underlying error.
+func (e recoverableError) Unwrap() error {
+	return e.error
+}
+
+// HTTPError is returned when the remote endpoint responds with a non-2xx
+// status code.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	// Body holds the beginning of the response body, truncated to the
+	// client's maximum error message length.
+	Body string
+	// Stats holds the rejection details sent by the server for write
+	// requests, if parsing them is enabled and the server sent them.
+	Stats *WriteResponseStats
+
+	retryAfter time.Duration
+}
+
+// WriteStatsContentType is the Content-Type of responses to write requests
+// that carry rejection details, as parsed into WriteResponseStats for errors
+// and into PartialWriteError for partial successes.
+// The body is a JSON object like
+//
+//	{"accepted": 10, "rejected": [{"series": "up{job=\"a\"}", "reason": "out_of_order"}]}
+const WriteStatsContentType = "application/vnd.prometheus.write-stats+json"
+
+// maxWriteStatsLen is the maximum number of bytes of write stats parsed.
+const maxWriteStatsLen = 1 << 20
+
+// WriteResponseStats summarizes which samples of a write request the server
+// accepted and why it rejected the others.
+type WriteResponseStats struct {
+	Accepted int
+	Rejected int
+	// Reasons counts rejected samples by the reason given by the server.
+	Reasons map[string]int
+}
+
+// RejectedSeries is a series the server rejected, with the reason given.
+type RejectedSeries struct {
+	Series string `json:"series"`
+	Reason string `json:"reason"`
+}
+
+// WriteResponse is the JSON object in WriteStatsContentType bodies, as
+// written by EncodeWriteResponse.
+type WriteResponse struct {
+	Accepted int              `json:"accepted"`
+	Rejected []RejectedSeries `json:"rejected"`
+}
+
+// parseWriteStats parses a WriteStatsContentType body.
+func parseWriteStats(body []byte) (*WriteResponseStats, error) {
+	var resp WriteResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	stats := &WriteResponseStats{
+		Accepted: resp.Accepted,
+		Rejected: len(resp.Rejected),
+		Reasons:  map[string]int{},
+	}
+	for _, r := range resp.Rejected {
+		stats.Reasons[r.Reason]++
+	}
+	return stats, nil
+}
+
+// PartialWriteError is returned for write requests the server accepted only
+// in part, as told by a successful response with a WriteStatsContentType
+// body listing rejected series. It is not recoverable, as resending the
+// request would duplicate the accepted samples.
+type PartialWriteError struct {
+	// Accepted is the number of samples the server accepted.
+	Accepted int
+	Rejected []RejectedSeries
+}
+
+func (e *PartialWriteError) Error() string {
+	if len(e.Rejected) == 1 {
+		return fmt.Sprintf("server rejected series %s: %s", e.Rejected[0].Series, e.Rejected[0].Reason)
+	}
+	return fmt.Sprintf("server rejected %d series, including %s: %s", len(e.Rejected), e.Rejected[0].Series, e.Rejected[0].Reason)
+}
+
+// parsePartialWrite returns a PartialWriteError if a successful write
+// response lists rejected series. Bodies that are not write stats, or that
+// cannot be parsed, leave the write successful.
+func parsePartialWrite(resp *http.Response) *PartialWriteError {
+	if !isWriteStats(resp.Header.Get("Content-Type")) {
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWriteStatsLen))
+	if err != nil {
+		return nil
+	}
+	var stats WriteResponse
+	if err := json.Unmarshal(body, &stats); err != nil || len(stats.Rejected) == 0 {
+		return nil
+	}
+	return &PartialWriteError{Accepted: stats.Accepted, Rejected: stats.Rejected}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("server returned HTTP status %s", e.Status)
+}
+
+// RetryAfter returns the delay the server asked for via the Retry-After
+// header of a 429 or 503 response, or 0 if it did not ask for one.
+func (e *HTTPError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// drainBody reads what is left of a response body, so that the connection
+// can be reused, and closes it. At most limit+1 bytes are read, as the
+// connections of longer bodies, such as error pages of misbehaving proxies,
+// are not worth reading them to the end.
+func drainBody(body io.ReadCloser, limit int) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, int64(limit)+1))
+	body.Close()
+}
+
+// newHTTPError builds an HTTPError from a response, consuming at most
+// maxErrMsgLen bytes of its body, unless the body holds write stats to be
+// parsed.
+func (c *Client) newHTTPError(resp *http.Response, parseStats bool) *HTTPError {
+	maxLen := c.maxErrMsgLen
+	parseStats = parseStats && isWriteStats(resp.Header.Get("Content-Type"))
+	limit := maxLen
+	if parseStats && limit < maxWriteStatsLen {
+		limit = maxWriteStatsLen
+	}
+	// A snappy block cannot be decoded from a prefix, so compressed bodies
+	// are read up to a larger limit and truncated once decoded.
+	encoded := resp.Header.Get("Content-Encoding") == SnappyCompression
+	if encoded && limit < maxEncodedErrBodyLen {
+		limit = maxEncodedErrBodyLen
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, int64(limit)))
+	if encoded {
+		body = decodeErrorBody(body)
+	}
+
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+	if parseStats {
+		// Malformed stats are left out, the body is kept regardless.
+		e.Stats, _ = parseWriteStats(body)
+	}
+	if len(body) > maxLen {
+		body = body[:maxLen]
+	}
+	e.Body = string(body)
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		e.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), c.clock.Now())
+	}
+	return e
+}
+
+// maxEncodedErrBodyLen is the maximum number of bytes read from, and decoded
+// from, a snappy-encoded error response body.
+const maxEncodedErrBodyLen = 64 * 1024
+
+// decodeErrorBody decodes a snappy-encoded error response body. The body is
+// returned as is if it cannot be decoded, as servers are not always right
+// about the encoding of their errors.
+func decodeErrorBody(body []byte) []byte {
+	if n, err := snappy.DecodedLen(body); err != nil || n > maxEncodedErrBodyLen {
+		return body
+	}
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
+
+// isWriteStats reports whether a Content-Type denotes write stats.
+func isWriteStats(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == WriteStatsContentType
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date. Missing, malformed, and past values
+// yield 0.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// Store sends a write request to the HTTP endpoint. Recoverable errors are
+// retried with exponential backoff if retrying is configured. Each attempt is
+// bounded by the client timeout or by the deadline of ctx, whichever is
+// earlier.
+//
+// If a maximum number of samples per send is configured, the request is split
+// into batches that are sent one after another. All batches are sent even if
+// some fail, and their errors are combined.
+//
+// A request without series or metadata is not sent at all.
+func (c *Client) Store(ctx context.Context, req *WriteRequest) error {
+	_, err := c.StoreWithStats(ctx, req)
+	return err
+}
+
+// WriteStats describes the write requests sent by StoreWithStats.
+type WriteStats struct {
+	// Sizes of the request bodies before and after compression.
+	UncompressedBytes int
+	CompressedBytes   int
+	SeriesCount       int
+	// UnsortedSeries is the number of series whose samples were out of
+	// order, and got sorted, if SortSamples is set.
+	UnsortedSeries int
+	// DuplicateSamples is the number of samples dropped as sent before
+	// within the DedupWindow.
+	DuplicateSamples int
+}
+
+// StoreWithStats works like Store, but also returns the sizes of the write
+// requests that were sent successfully. With a dry run, these are the sizes
+// the requests would have had.
+func (c *Client) StoreWithStats(ctx context.Context, req *WriteRequest) (stats WriteStats, err error) {
+	if c.url == nil {
+		return stats, errNoWriteURL
+	}
+	// Nothing to send, don't waste a round trip on it.
+	if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
+		return stats, nil
+	}
+	if c.maxSeriesPerRequest > 0 && len(req.Timeseries) > c.maxSeriesPerRequest {
+		return stats, fmt.Errorf("write request has %d series, more than the maximum of %d", len(req.Timeseries), c.maxSeriesPerRequest)
+	}
+	span, ctx := c.startSpan(ctx, "store", c.url)
+	defer func() { finishSpan(span, err) }()
+	samples := 0
+	for _, ts := range req.Timeseries {
+		samples += len(ts.Samples)
+	}
+	span.SetTag(samplesTag, samples)
+	if c.slowRequestThreshold > 0 {
+		begin := c.clock.Now()
+		defer func() {
+			if d := c.clock.Now().Sub(begin); d > c.slowRequestThreshold {
+				c.logger.
+					With("url", c.url.String()).
+					With("samples", samples).
+					With("duration", d).
+					With("err", err).
+					Warn("Slow remote storage Store call")
+			}
+		}()
+	}
+
+	if c.inflight != nil {
+		select {
+		case c.inflight <- struct{}{}:
+			defer func() { <-c.inflight }()
+		case <-ctx.Done():
+			return stats, ctx.Err()
+		}
+	}
+	inflight := c.metrics.inflight.WithLabelValues(c.url.String())
+	inflight.Inc()
+	defer inflight.Dec()
+
+	if c.validateHistograms {
+		if err := validateHistograms(req); err != nil {
+			return stats, err
+		}
+	}
+	if len(c.writeRelabelConfigs) > 0 {
+		req = relabelWriteRequest(req, c.writeRelabelConfigs)
+	}
+	if c.metricNamePrefix != "" || c.metricNameSuffix != "" {
+		req = renameMetrics(req, c.metricNamePrefix, c.metricNameSuffix)
+	}
+	if c.labelKeep != nil || c.labelDrop != nil {
+		req = filterLabels(req, c.labelKeep, c.labelDrop)
+	}
+	if !c.sendExemplars {
+		req = stripExemplars(req)
+	}
+	if c.validateLabels {
+		if err := validateLabels(req, c.utf8LabelNames); err != nil {
+			return stats, err
+		}
+	}
+	if c.dropStaleMarkers {
+		req = dropStaleMarkers(req)
+	}
+	if c.maxSampleAge > 0 {
+		var dropped int
+		minTimestamp := c.clock.Now().Add(-c.maxSampleAge).UnixNano() / int64(time.Millisecond)
+		if req, dropped = dropOldSamples(req, minTimestamp); dropped > 0 {
+			c.metrics.droppedSamples.WithLabelValues(c.url.String(), "too_old").Add(float64(dropped))
+		}
+	}
+	if c.sampleTransformer != nil {
+		req = transformSamples(req, c.sampleTransformer)
+	}
+	if c.dedup != nil {
+		req, stats.DuplicateSamples = c.dedup.filter(req, c.clock.Now())
+	}
+	if c.sortSamples {
+		req, stats.UnsortedSeries = sortSamples(req)
+	}
+	// Don't send requests that had all their series dropped.
+	if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
+		return stats, nil
+	}
+	batches := splitWriteRequest(req, c.maxSamplesPerSend)
+	var errs []error
+	for _, batch := range batches {
+		var batchStats WriteStats
+		err := c.storeBatch(ctx, batch, &batchStats)
+		stats.UncompressedBytes += batchStats.UncompressedBytes
+		stats.CompressedBytes += batchStats.CompressedBytes
+		stats.SeriesCount += batchStats.SeriesCount
+		if err != nil {
+			if c.onError != nil {
+				c.onError(err, batchStats)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if c.onSuccess != nil {
+			c.onSuccess(batchStats)
+		}
+		if c.dedup != nil {
+			c.dedup.record(batch, c.clock.Now())
+		}
+	}
+	return stats, combineErrors(errs)
+}
+
+// EstimateSize returns the sizes of a write request before and after
+// compression, as sent with the protocol version and compression of the
+// client. The request is measured as given, before any relabeling,
+// filtering or splitting Store would apply.
+func (c *Client) EstimateSize(req *WriteRequest) (uncompressed, compressed int, err error) {
+	bufs := writeBufferPool.Get().(*writeBuffers)
+	defer writeBufferPool.Put(bufs)
+
+	var msg proto.Message = req
+	if c.protocolVersion() == ProtocolVersion2 {
+		msg = toWriteRequestV2(req)
+	}
+	data, comp, _, err := c.encodeWriteRequest(msg, bufs)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(data), len(comp), nil
+}
+
+// CompressionBySize picks the compression of write requests by their size
+// before compression, such as snappy for small ones, as it is fast, and zstd
+// for large ones, as it compresses better.
+type CompressionBySize struct {
+	// Requests of at least Threshold bytes are compressed with Large, and
+	// smaller ones with Small, which default to zstd and snappy.
+	Threshold int
+	Small     string
+	Large     string
+}
+
+// encodeWriteRequest marshals and compresses a write request into bufs, as
+// the package function does, with the compression chosen for its size,
+// which is returned too.
+func (c *Client) encodeWriteRequest(msg proto.Message, bufs *writeBuffers) (data, compressed []byte, compression string, err error) {
+	compression, level := c.compression, c.compressionLevel
+	if c.bySize != nil {
+		if proto.Size(msg) < c.bySize.Threshold {
+			compression, level = c.bySize.Small, 0
+		} else {
+			compression = c.bySize.Large
+		}
+	}
+	data, compressed, err = encodeWriteRequest(msg, compression, level, bufs)
+	return data, compressed, compression, err
+}
+
+// add accounts for a sent write request. A nil WriteStats is a no-op.
+func (s *WriteStats) add(req *WriteRequest, data, compressed []byte) {
+	if s == nil {
+		return
+	}
+	s.UncompressedBytes += len(data)
+	s.CompressedBytes += len(compressed)
+	s.SeriesCount += len(req.Timeseries)
+}
+
+// storeBatch sends a single batch. If the server rejects it as too large, it
+// is halved and both halves are sent, without halving any further.
+func (c *Client) storeBatch(ctx context.Context, req *WriteRequest, stats *WriteStats) error {
+	err := c.writeFailover(ctx, req, stats)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusRequestEntityTooLarge || len(req.Timeseries) < 2 {
+		return err
+	}
+
+	half := len(req.Timeseries) / 2
+	var errs []error
+	for _, batch := range []*WriteRequest{
+		{Timeseries: req.Timeseries[:half], Metadata: req.Metadata},
+		{Timeseries: req.Timeseries[half:]},
+	} {
+		if err := c.writeFailover(ctx, batch, stats); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return combineErrors(errs)
+}
+
+// relabelWriteRequest returns a write request with the relabeling rules
+// applied to all series, leaving out dropped ones. The given request is left
+// untouched.
+func relabelWriteRequest(req *WriteRequest, cfgs []*config.RelabelConfig) *WriteRequest {
+	relabeled := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(req.Timeseries)),
+		Metadata:   req.Metadata,
+	}
+	for _, ts := range req.Timeseries {
+		ls := make(model.LabelSet, len(ts.Labels))
+		for _, l := range ts.Labels {
+			ls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		}
+		ls = relabel.Process(ls, cfgs...)
+		if len(ls) == 0 {
+			continue
+		}
+
+		names := make(model.LabelNames, 0, len(ls))
+		for name := range ls {
+			names = append(names, name)
+		}
+		sort.Sort(names)
+
+		cp := *ts
+		cp.Labels = make([]*LabelPair, 0, len(names))
+		for _, name := range names {
+			cp.Labels = append(cp.Labels, &LabelPair{Name: string(name), Value: string(ls[name])})
+		}
+		relabeled.Timeseries = append(relabeled.Timeseries, &cp)
+	}
+	return relabeled
+}
+
+// labelSet returns the set of the given label names, nil if there are none.
+func labelSet(names []string) map[string]struct{} {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+// renameMetrics returns a write request with prefix and suffix added to the
+// metric names of its series and metadata. Series without a metric name are
+// left as they are. The given request is left untouched.
+func renameMetrics(req *WriteRequest, prefix, suffix string) *WriteRequest {
+	renamed := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(req.Timeseries)),
+		Metadata:   make([]*MetricMetadata, 0, len(req.Metadata)),
+	}
+	for _, ts := range req.Timeseries {
+		cp := *ts
+		cp.Labels = make([]*LabelPair, 0, len(ts.Labels))
+		for _, l := range ts.Labels {
+			if l.Name == model.MetricNameLabel {
+				l = &LabelPair{Name: l.Name, Value: prefix + l.Value + suffix}
+			}
+			cp.Labels = append(cp.Labels, l)
+		}
+		renamed.Timeseries = append(renamed.Timeseries, &cp)
+	}
+	for _, md := range req.Metadata {
+		cp := *md
+		cp.MetricFamilyName = prefix + md.MetricFamilyName + suffix
+		renamed.Metadata = append(renamed.Metadata, &cp)
+	}
+	return renamed
+}
+
+// filterLabels returns a write request with only the labels in keep, if it
+// is not nil, and none in drop, always keeping the metric name label. The
+// given request is left untouched.
+func filterLabels(req *WriteRequest, keep, drop map[string]struct{}) *WriteRequest {
+	filtered := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(req.Timeseries)),
+		Metadata:   req.Metadata,
+	}
+	for _, ts := range req.Timeseries {
+		cp := *ts
+		cp.Labels = make([]*LabelPair, 0, len(ts.Labels))
+		for _, l := range ts.Labels {
+			if l.Name != model.MetricNameLabel {
+				if _, ok := keep[l.Name]; keep != nil && !ok {
+					continue
+				}
+				if _, ok := drop[l.Name]; ok {
+					continue
+				}
+			}
+			cp.Labels = append(cp.Labels, l)
+		}
+		filtered.Timeseries = append(filtered.Timeseries, &cp)
+	}
+	return filtered
+}
+
+// transformSamples returns a write request with copies of all samples,
+// passed to the transformer. The given request is left untouched.
+func transformSamples(req *WriteRequest, transform func([]*LabelPair, *Sample)) *WriteRequest {
+	transformed := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(req.Timeseries)),
+		Metadata:   req.Metadata,
+	}
+	for _, ts := range req.Timeseries {
+		cp := *ts
+		samples := make([]Sample, len(ts.Samples))
+		cp.Samples = make([]*Sample, len(ts.Samples))
+		for i, s := range ts.Samples {
+			samples[i] = *s
+			transform(ts.Labels, &samples[i])
+			cp.Samples[i] = &samples[i]
+		}
+		transformed.Timeseries = append(transformed.Timeseries, &cp)
+	}
+	return transformed
+}
+
+// stripExemplars returns a write request without exemplars. The given
+// request is left untouched.
+func stripExemplars(req *WriteRequest) *WriteRequest {
+	var stripped *WriteRequest
+	for i, ts := range req.Timeseries {
+		if len(ts.Exemplars) == 0 {
+			continue
+		}
+		if stripped == nil {
+			stripped = &WriteRequest{
+				Timeseries: append([]*TimeSeries(nil), req.Timeseries...),
+				Metadata:   req.Metadata,
+			}
+		}
+		cp := *ts
+		cp.Exemplars = nil
+		stripped.Timeseries[i] = &cp
+	}
+	if stripped == nil {
+		return req
+	}
+	return stripped
+}
+
+// staleNaNBits is the bit pattern of the NaN value Prometheus marks series
+// as stale with, distinguishing it from NaN values that were scraped.
+const staleNaNBits uint64 = 0x7ff0000000000002
+
+// isStaleMarker returns whether a sample value is a staleness marker.
+func isStaleMarker(v float64) bool {
+	return math.Float64bits(v) == staleNaNBits
+}
+
+// dropStaleMarkers returns a write request without staleness markers,
+// leaving out series that only had stale samples. The given request is left
+// untouched.
+func dropStaleMarkers(req *WriteRequest) *WriteRequest {
+	var dropped *WriteRequest
+	for i, ts := range req.Timeseries {
+		samples := make([]*Sample, 0, len(ts.Samples))
+		for _, s := range ts.Samples {
+			if !isStaleMarker(s.Value) {
+				samples = append(samples, s)
+			}
+		}
+		if len(samples) == len(ts.Samples) {
+			if dropped != nil {
+				dropped.Timeseries = append(dropped.Timeseries, ts)
+			}
+			continue
+		}
+		if dropped == nil {
+			dropped = &WriteRequest{
+				Timeseries: append(make([]*TimeSeries, 0, len(req.Timeseries)), req.Timeseries[:i]...),
+				Metadata:   req.Metadata,
+			}
+		}
+		if len(samples) == 0 && len(ts.Histograms) == 0 {
+			continue
+		}
+		cp := *ts
+		cp.Samples = samples
+		dropped.Timeseries = append(dropped.Timeseries, &cp)
+	}
+	if dropped == nil {
+		return req
+	}
+	return dropped
+}
+
+// dropOldSamples returns a write request without the samples and histogram
+// samples older than the given timestamp, leaving out series that only had
+// old ones, and the number of samples dropped. The given request is left
+// untouched.
+func dropOldSamples(req *WriteRequest, minTimestampMs int64) (*WriteRequest, int) {
+	var (
+		filtered *WriteRequest
+		dropped  int
+	)
+	for i, ts := range req.Timeseries {
+		samples := make([]*Sample, 0, len(ts.Samples))
+		for _, s := range ts.Samples {
+			if s.TimestampMs >= minTimestampMs {
+				samples = append(samples, s)
+			}
+		}
+		histograms := make([]*Histogram, 0, len(ts.Histograms))
+		for _, h := range ts.Histograms {
+			if h.TimestampMs >= minTimestampMs {
+				histograms = append(histograms, h)
+			}
+		}
+		n := len(ts.Samples) - len(samples) + len(ts.Histograms) - len(histograms)
+		if n == 0 {
+			if filtered != nil {
+				filtered.Timeseries = append(filtered.Timeseries, ts)
+			}
+			continue
+		}
+		dropped += n
+		if filtered == nil {
+			filtered = &WriteRequest{
+				Timeseries: append(make([]*TimeSeries, 0, len(req.Timeseries)), req.Timeseries[:i]...),
+				Metadata:   req.Metadata,
+			}
+		}
+		if len(samples) == 0 && len(histograms) == 0 {
+			continue
+		}
+		cp := *ts
+		cp.Samples = samples
+		cp.Histograms = histograms
+		filtered.Timeseries = append(filtered.Timeseries, &cp)
+	}
+	if filtered == nil {
+		return req, 0
+	}
+	return filtered, dropped
+}
+
+// sortSamples returns a write request with the samples of every series
+// sorted by timestamp, and the number of series that were not sorted. The
+// given request is left untouched.
+func sortSamples(req *WriteRequest) (*WriteRequest, int) {
+	var (
+		sorted   *WriteRequest
+		unsorted int
+	)
+	for i, ts := range req.Timeseries {
+		if sort.SliceIsSorted(ts.Samples, func(a, b int) bool { return ts.Samples[a].TimestampMs < ts.Samples[b].TimestampMs }) {
+			continue
+		}
+		unsorted++
+		if sorted == nil {
+			sorted = &WriteRequest{
+				Timeseries: append([]*TimeSeries(nil), req.Timeseries...),
+				Metadata:   req.Metadata,
+			}
+		}
+		cp := *ts
+		cp.Samples = append([]*Sample(nil), ts.Samples...)
+		sort.SliceStable(cp.Samples, func(a, b int) bool { return cp.Samples[a].TimestampMs < cp.Samples[b].TimestampMs })
+		sorted.Timeseries[i] = &cp
+	}
+	if sorted == nil {
+		return req, 0
+	}
+	return sorted, unsorted
+}
+
+// splitWriteRequest splits a write request into requests of at most max
+// samples each, never splitting a single time series. Metadata goes with the
+// first request. A max of 0 or less disables splitting.
+func splitWriteRequest(req *WriteRequest, max int) []*WriteRequest {
+	if max <= 0 {
+		return []*WriteRequest{req}
+	}
+
+	var (
+		batches = []*WriteRequest{{Metadata: req.Metadata}}
+		samples int
+	)
+	for _, ts := range req.Timeseries {
+		last := batches[len(batches)-1]
+		if len(last.Timeseries) > 0 && samples+len(ts.Samples) > max {
+			last = &WriteRequest{}
+			batches = append(batches, last)
+			samples = 0
+		}
+		last.Timeseries = append(last.Timeseries, ts)
+		samples += len(ts.Samples)
+	}
+	return batches
+}
+
+// combineErrors combines the errors of several write requests into one, which
+// is recoverable if all of them are.
+func combineErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	recoverable := true
+	for _, err := range errs {
+		if _, ok := err.(recoverableError); !ok {
+			recoverable = false
+		}
+	}
+	err := fmt.Errorf("%d write requests failed: %w", len(errs), errors.Join(errs...))
+	if recoverable {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// StoreMetadata sends metric metadata to the HTTP endpoint, or to the
+// metadata URL if one is configured. Compression, timeouts and retries work
+// as for Store. Metadata is always sent with remote write protocol 1.0, as
+// 2.0 only carries it along with series.
+func (c *Client) StoreMetadata(ctx context.Context, metadata []*MetricMetadata) (err error) {
+	if err := c.checkOpen(); err != nil {
+		return err
+	}
+	if c.metadataURL == nil {
+		return errNoWriteURL
+	}
+	span, ctx := c.startSpan(ctx, "store_metadata", c.metadataURL)
+	defer func() { finishSpan(span, err) }()
+	return c.writeWithVersion(ctx, c.metadataURL, &WriteRequest{Metadata: metadata}, nil, ProtocolVersion1)
 }
 
-type clientConfig struct {
-	url              *config.URL
-	timeout          model.Duration
-	httpClientConfig config.HTTPClientConfig
+// protocolVersion returns the remote write protocol version to use, which is
+// 1.0 once the server turned out not to support the configured 2.0.
+func (c *Client) protocolVersion() string {
+	if atomic.LoadInt32(&c.downgraded) == 1 {
+		return ProtocolVersion1
+	}
+	return c.version
 }
 
-// NewClient creates a new Client.
-func NewClient(index int, conf *clientConfig) (*Client, error) {
-	httpClient, err := httputil.NewClientFromConfig(conf.httpClientConfig)
+// write sends a write request to the given URL with the current protocol
+// version. If the server rejects remote write protocol 2.0 with a 415
+// response, the request is sent again with 1.0, which is used for all further
+// requests.
+func (c *Client) write(ctx context.Context, u *config.URL, req *WriteRequest, stats *WriteStats) error {
+	if err := c.checkOpen(); err != nil {
+		return err
+	}
+	version := c.protocolVersion()
+	err := c.writeWithVersion(ctx, u, req, stats, version)
+	var httpErr *HTTPError
+	if version == ProtocolVersion2 && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnsupportedMediaType {
+		c.logger.With("url", u.String()).Warn("Remote storage does not support remote write 2.0, falling back to 1.0")
+		atomic.StoreInt32(&c.downgraded, 1)
+		return c.writeWithVersion(ctx, u, req, stats, ProtocolVersion1)
+	}
+	return err
+}
+
+// writeWithVersion sends a write request to the given URL, retrying
+// recoverable errors with exponential backoff if retrying is configured. If
+// stats is not nil, the request is added to them once it has been sent
+// successfully.
+func (c *Client) writeWithVersion(ctx context.Context, u *config.URL, req *WriteRequest, stats *WriteStats, version string) error {
+	bufs := writeBufferPool.Get().(*writeBuffers)
+	defer writeBufferPool.Put(bufs)
+
+	var (
+		msg      proto.Message = req
+		relative bool
+	)
+	switch {
+	case version == ProtocolVersion2:
+		msg = toWriteRequestV2(req)
+	case c.optimizeTimestamps && u == c.url && c.supportsRelativeTimestamps(ctx):
+		msg = relativeTimestamps(req)
+		relative = true
+	}
+	// Don't spend time compressing requests that cannot be sent anymore.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, compressed, compression, err := c.encodeWriteRequest(msg, bufs)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &Client{
-		index:   index,
-		url:     conf.url,
-		client:  httpClient,
-		timeout: time.Duration(conf.timeout),
-	}, nil
+	if c.dryRun {
+		c.metrics.sentBytes.WithLabelValues(u.String(), "uncompressed").Add(float64(len(data)))
+		c.metrics.sentBytes.WithLabelValues(u.String(), "compressed").Add(float64(len(compressed)))
+		stats.add(req, data, compressed)
+		return nil
+	}
+
+	breaker := c.breakers[u.String()]
+	backoff := c.retryMinBackoff
+	for attempt := 1; ; attempt++ {
+		if err := breaker.allow(); err != nil {
+			return recoverableError{err}
+		}
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag(attemptTag, attempt)
+		}
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("not sending write request within rate limit: %s", err)
+			}
+		}
+		// The deadline may have passed while compressing.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.metrics.sentBytes.WithLabelValues(u.String(), "uncompressed").Add(float64(len(data)))
+		c.metrics.sentBytes.WithLabelValues(u.String(), "compressed").Add(float64(len(compressed)))
+		err = c.store(ctx, u, compressed, compression, version, relative)
+		_, recoverable := err.(recoverableError)
+		if ctx.Err() != nil {
+			breaker.abort()
+		} else {
+			// Unrecoverable errors show that the endpoint is up.
+			breaker.record(recoverable)
+		}
+		partial, _ := err.(*PartialWriteError)
+		if err == nil || partial != nil {
+			samples := 0
+			if partial != nil {
+				samples = partial.Accepted
+			} else {
+				for _, ts := range req.Timeseries {
+					samples += len(ts.Samples)
+				}
+			}
+			c.metrics.sentSamples.WithLabelValues(u.String()).Add(float64(samples))
+			c.recordSuccess(u)
+			stats.add(req, data, compressed)
+			return err
+		}
+		if !recoverable {
+			return err
+		}
+		if attempt >= c.retryMaxAttempts {
+			if attempt > 1 {
+				err = recoverableError{fmt.Errorf("giving up after %d attempts: %w", attempt, err.(recoverableError).error)}
+			}
+			return err
+		}
+
+		select {
+		case <-c.clock.After(c.retrySleep(backoff, err)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.metrics.retries.WithLabelValues(u.String()).Inc()
+		backoff = backoff * 2
+		if backoff > c.retryMaxBackoff {
+			backoff = c.retryMaxBackoff
+		}
+	}
 }
 
-type recoverableError struct {
-	error
+// recordSuccess records that the endpoint accepted a write request.
+func (c *Client) recordSuccess(u *config.URL) {
+	now := c.clock.Now()
+	atomic.StoreInt64(&c.lastSuccess, now.UnixNano())
+	c.metrics.lastSuccess.WithLabelValues(u.String()).Set(float64(now.UnixNano()) / 1e9)
 }
 
-// Store sends a batch of samples to the HTTP endpoint.
-func (c *Client) Store(samples model.Samples) error {
-	req := &WriteRequest{
-		Timeseries: make([]*TimeSeries, 0, len(samples)),
+// LastSuccessTime returns when the remote endpoint last accepted a write
+// request, or the zero time if it has not accepted any yet. Dry runs do not
+// count.
+func (c *Client) LastSuccessTime() time.Time {
+	ns := atomic.LoadInt64(&c.lastSuccess)
+	if ns == 0 {
+		return time.Time{}
 	}
-	for _, s := range samples {
-		ts := &TimeSeries{
-			Labels: make([]*LabelPair, 0, len(s.Metric)),
+	return time.Unix(0, ns)
+}
+
+// ServerWriteVersion returns the remote write version the server reported in
+// the X-Prometheus-Remote-Write-Version header of its last successful write
+// response, or "" if it did not report one. Comparing it to the version sent
+// reveals servers that accept requests they do not fully support.
+func (c *Client) ServerWriteVersion() string {
+	v, _ := c.serverVersion.Load().(string)
+	return v
+}
+
+// retrySleep returns how long to sleep before retrying a request that failed
+// with err, given the current backoff.
+func (c *Client) retrySleep(backoff time.Duration, err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter() > 0 {
+		// Servers or proxies may ask for much longer than is worth
+		// waiting for.
+		if c.retryMaxBackoff > 0 && httpErr.RetryAfter() > c.retryMaxBackoff {
+			return c.retryMaxBackoff
 		}
-		for k, v := range s.Metric {
-			ts.Labels = append(ts.Labels,
-				&LabelPair{
-					Name:  string(k),
-					Value: string(v),
-				})
+		return httpErr.RetryAfter()
+	}
+	if c.jitter == nil || backoff <= 0 {
+		return backoff
+	}
+	return c.jitter.between0And(backoff)
+}
+
+// jitter draws random durations for full jitter. It is safe for concurrent
+// use.
+type jitter struct {
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+// newJitter returns a jitter if enabled, nil otherwise.
+func newJitter(enabled bool) *jitter {
+	if !enabled {
+		return nil
+	}
+	return &jitter{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// between0And returns a random duration between 0 and d, inclusive.
+func (j *jitter) between0And(d time.Duration) time.Duration {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	return time.Duration(j.rand.Int63n(int64(d) + 1))
+}
+
+// checkRedirect returns an http.Client redirect policy following at most max
+// 307 and 308 redirects. Once it stops, the redirect response itself is
+// returned.
+func checkRedirect(max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return http.ErrUseLastResponse
 		}
-		ts.Samples = []*Sample{
-			{
-				Value:       float64(s.Value),
-				TimestampMs: int64(s.Timestamp),
-			},
+		switch req.Response.StatusCode {
+		case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			return nil
 		}
-		req.Timeseries = append(req.Timeseries, ts)
+		return http.ErrUseLastResponse
 	}
+}
 
-	data, err := proto.Marshal(req)
+// newWriteHTTPRequest creates the HTTP request for a compressed write
+// request of the given protocol version, optionally with relative
+// timestamps.
+func (c *Client) newWriteHTTPRequest(u *config.URL, body io.Reader, compression, version string, relative bool) (*http.Request, error) {
+	httpReq, err := http.NewRequest("POST", u.String(), body)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if compression == NoCompression {
+		compression = identityEncoding
 	}
+	httpReq.Header.Add("Content-Encoding", compression)
+	httpReq.Header.Set("Content-Type", writeContentTypes[version])
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", writeVersionHeaders[version])
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if relative {
+		httpReq.Header.Set(TimestampEncodingHeader, RelativeTimestamps)
+	}
+	return httpReq, nil
+}
 
-	compressed := snappy.Encode(nil, data)
-	httpReq, err := http.NewRequest("POST", c.url.String(), bytes.NewBuffer(compressed))
+// store makes a single attempt at sending the compressed write request.
+func (c *Client) store(ctx context.Context, u *config.URL, compressed []byte, compression, version string, relative bool) error {
+	httpReq, err := c.newWriteHTTPRequest(u, bytes.NewReader(compressed), compression, version, relative)
 	if err != nil {
 		// Errors from NewRequest are from unparseable URLs, so are not
 		// recoverable.
 		return err
 	}
-	httpReq.Header.Add("Content-Encoding", "snappy")
-	httpReq.Header.Set("Content-Type", "application/x-protobuf")
-	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	parent := ctx
+	timeout := c.storeTimeout(u)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	httpResp, err := ctxhttp.Do(ctx, c.client, httpReq)
+	c.injectSpan(ctx, httpReq)
+	if c.recorder != nil {
+		if err := c.recorder.RecordRequest(httpReq.Method, httpReq.URL.String(), httpReq.Header, compressed); err != nil {
+			c.logger.With("url", u.String()).Warnf("Error recording write request: %s", err)
+		}
+	}
+
+	begin := c.clock.Now()
+	httpResp, err := c.do(ctx, u, httpReq)
+	c.metrics.observeRequest(u, "store", c.clock.Now().Sub(begin), httpResp, err)
 	if err != nil {
+		c.logRequest(u, "store", len(compressed), begin, nil, err)
+		// Requests canceled by the caller, or past its deadline, are
+		// returned as the context error. Attempts timing out while the
+		// caller still waits are recoverable, as the next one may make it.
+		if err := parent.Err(); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			if c.latencies != nil {
+				c.latencies.observe(timeout)
+			}
+			return recoverableError{err}
+		}
 		// Errors from client.Do are from (for example) network errors, so are
 		// recoverable.
 		return recoverableError{err}
 	}
-	defer httpResp.Body.Close()
+	defer drainBody(httpResp.Body, c.maxErrMsgLen)
+
+	observeResponse(ctx, httpResp)
+	if httpResp.StatusCode/100 == 2 {
+		c.serverVersion.Store(httpResp.Header.Get("X-Prometheus-Remote-Write-Version"))
+		if c.latencies != nil {
+			c.latencies.observe(c.clock.Now().Sub(begin))
+		}
+		if c.parsePartial {
+			if partial := parsePartialWrite(httpResp); partial != nil {
+				err = partial
+			}
+		}
+		c.logRequest(u, "store", len(compressed), begin, httpResp, err)
+		return err
+	}
+	httpErr := c.newHTTPError(httpResp, c.parseWriteStats)
+	c.logRequest(u, "store", len(compressed), begin, httpResp, httpErr)
+	_, retry := c.retryOn[httpResp.StatusCode]
+	if c.recoverable(httpErr, retry || httpResp.StatusCode/100 == 5 || httpResp.StatusCode == http.StatusTooManyRequests) {
+		return recoverableError{httpErr}
+	}
+	return httpErr
+}
+
+// do sends an HTTP request to u, accounting for it as in flight until the
+// response headers arrived.
+func (c *Client) do(ctx context.Context, u *config.URL, httpReq *http.Request) (*http.Response, error) {
+	inflight := c.metrics.inflightReqs.WithLabelValues(u.String())
+	inflight.Inc()
+	defer inflight.Dec()
+	return ctxhttp.Do(ctx, c.client, httpReq)
+}
+
+// recoverable returns whether a request failing with the given error is
+// to be retried, as the configured classifier decides if there is one, or
+// as given by the built-in rules otherwise.
+func (c *Client) recoverable(err *HTTPError, builtin bool) bool {
+	if c.classifyError == nil {
+		return builtin
+	}
+	return c.classifyError(err.StatusCode, []byte(err.Body))
+}
+
+// defaultHealthTimeout is the default timeout of health checks.
+const defaultHealthTimeout = 5 * time.Second
+
+// Healthy checks whether the remote endpoint is reachable and responsive. It
+// sends a HEAD request to the health URL if one is configured, and an empty
+// write request otherwise. Both 2xx and 405 responses count as healthy.
+func (c *Client) Healthy(ctx context.Context) error {
+	if err := c.checkOpen(); err != nil {
+		return err
+	}
+	var (
+		httpReq *http.Request
+		err     error
+	)
+	if c.healthURL != nil {
+		httpReq, err = http.NewRequest("HEAD", c.healthURL.String(), nil)
+		if err == nil {
+			httpReq.Header.Set("User-Agent", c.userAgent)
+		}
+	} else {
+		if c.url == nil {
+			return errNoWriteURL
+		}
+		var compressed []byte
+		compressed, err = compress(c.compression, nil)
+		if err != nil {
+			return err
+		}
+		httpReq, err = c.newWriteHTTPRequest(c.url, bytes.NewReader(compressed), c.compression, c.protocolVersion(), false)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.healthTimeout)
+	defer cancel()
+
+	httpResp, err := ctxhttp.Do(ctx, c.client, httpReq)
+	if err != nil {
+		return err
+	}
+	defer drainBody(httpResp.Body, c.maxErrMsgLen)
+
+	if httpResp.StatusCode/100 == 2 || httpResp.StatusCode == http.StatusMethodNotAllowed {
+		return nil
+	}
+	return c.newHTTPError(httpResp, false)
+}
+
+// Ping sends an empty write request to the write URL and returns how long the
+// server took to respond successfully. The request goes through the same
+// transport, authorization and timeout as those sent by Store.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	if err := c.checkOpen(); err != nil {
+		return 0, err
+	}
+	if c.url == nil {
+		return 0, errNoWriteURL
+	}
+	compressed, err := compress(c.compression, nil)
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := c.newWriteHTTPRequest(c.url, bytes.NewReader(compressed), c.compression, c.protocolVersion(), false)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	begin := c.clock.Now()
+	httpResp, err := ctxhttp.Do(ctx, c.client, httpReq)
+	c.metrics.observeRequest(c.url, "ping", c.clock.Now().Sub(begin), httpResp, err)
+	if err != nil {
+		return 0, err
+	}
+	defer drainBody(httpResp.Body, c.maxErrMsgLen)
+	rtt := c.clock.Now().Sub(begin)
 
 	if httpResp.StatusCode/100 != 2 {
-		err = fmt.Errorf("server returned HTTP status %s", httpResp.Status)
+		return 0, c.newHTTPError(httpResp, false)
 	}
-	if httpResp.StatusCode/100 == 5 {
-		return recoverableError{err}
+	return rtt, nil
+}
+
+// Close closes the idle connections of the client and makes all further
+// requests fail with ErrClientClosed. Requests in flight are not interrupted.
+func (c *Client) Close() {
+	atomic.StoreInt32(&c.closed, 1)
+	for _, t := range c.idleClosers {
+		t.CloseIdleConnections()
 	}
-	return err
+}
+
+// checkOpen returns ErrClientClosed if the client has been closed.
+func (c *Client) checkOpen() error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrClientClosed
+	}
+	return nil
 }
 
 // Name identifies the client.
@@ -130,65 +2471,398 @@ func (c Client) Name() string {
 	return fmt.Sprintf("%d:%s", c.index, c.url)
 }
 
-// Read reads from a remote endpoint.
-func (c *Client) Read(ctx context.Context, from, through model.Time, matchers metric.LabelMatchers) (model.Matrix, error) {
+// Read reads from a remote endpoint. Queries over time ranges longer than
+// the read chunk duration are split into sub-queries sent one after another,
+// each with the configured timeout.
+func (c *Client) Read(ctx context.Context, query *Query) (*QueryResult, error) {
+	queries := splitQuery(query, c.readChunk)
+	if len(queries) == 1 {
+		return c.read(ctx, query)
+	}
+
+	results := make([]*QueryResult, 0, len(queries))
+	for _, q := range queries {
+		res, err := c.read(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return mergeQueryResults(results), nil
+}
+
+// read sends a single query to the remote read endpoint.
+func (c *Client) read(ctx context.Context, query *Query) (*QueryResult, error) {
 	req := &ReadRequest{
 		// TODO: Support batching multiple queries into one read request,
 		// as the protobuf interface allows for it.
-		Queries: []*Query{{
-			StartTimestampMs: int64(from),
-			EndTimestampMs:   int64(through),
-			Matchers:         labelMatchersToProto(matchers),
-		}},
+		Queries: []*Query{query},
 	}
 
-	data, err := proto.Marshal(req)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	httpResp, err := c.sendReadRequest(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("unable to marshal read request: %v", err)
+		return nil, err
 	}
+	defer httpResp.Body.Close()
 
-	compressed := snappy.Encode(nil, data)
-	httpReq, err := http.NewRequest("POST", c.url.String(), bytes.NewBuffer(compressed))
+	return readResult(httpResp, len(req.Queries))
+}
+
+// ReadStream is an iterator over the series of a streamed read response.
+type ReadStream struct {
+	cancel   context.CancelFunc
+	body     io.ReadCloser
+	reader   *ChunkedReader
+	pending  []*ChunkedSeries
+	buffered *QueryResult
+}
+
+// Next returns the next series of the response as it arrives. It returns
+// io.EOF once the response is exhausted.
+func (s *ReadStream) Next() (*ChunkedSeries, error) {
+	for len(s.pending) == 0 {
+		if s.reader == nil {
+			return nil, io.EOF
+		}
+		var resp ChunkedReadResponse
+		if err := s.reader.NextProto(&resp); err != nil {
+			return nil, err
+		}
+		s.pending = resp.ChunkedSeries
+	}
+	cs := s.pending[0]
+	s.pending = s.pending[1:]
+	return cs, nil
+}
+
+// Buffered returns the result of the query if the server does not support
+// streaming and responded with a regular sample response instead. In that
+// case Next returns io.EOF right away.
+func (s *ReadStream) Buffered() *QueryResult {
+	return s.buffered
+}
+
+// Close releases the resources held by the stream.
+func (s *ReadStream) Close() error {
+	defer s.cancel()
+	if s.body == nil {
+		return nil
+	}
+	return s.body.Close()
+}
+
+// ReadStream reads from a remote endpoint, asking for the response to be
+// streamed as chunked series so that memory usage stays flat for large
+// queries. Servers without streaming support are handled transparently, see
+// ReadStream.Buffered. The returned stream must be closed.
+func (c *Client) ReadStream(ctx context.Context, query *Query) (*ReadStream, error) {
+	req := &ReadRequest{
+		Queries:               []*Query{query},
+		AcceptedResponseTypes: []ReadRequest_ResponseType{ReadRequest_STREAMED_XOR_CHUNKS, ReadRequest_SAMPLES},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	httpResp, err := c.sendReadRequest(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		cancel()
+		return nil, err
+	}
+
+	if httpResp.Header.Get("Content-Type") != StreamedContentType {
+		defer httpResp.Body.Close()
+		res, err := readResult(httpResp, len(req.Queries))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &ReadStream{cancel: cancel, buffered: res}, nil
+	}
+
+	return &ReadStream{
+		cancel: cancel,
+		body:   httpResp.Body,
+		reader: NewChunkedReader(httpResp.Body, DefaultChunkedFrameLimit, nil),
+	}, nil
+}
+
+// LabelNames returns the label names known to the remote endpoint, limited
+// to series matching the given matchers if there are any. It returns nil if
+// no label names URL is configured.
+func (c *Client) LabelNames(ctx context.Context, matchers []*LabelMatcher) ([]string, error) {
+	if c.labelNamesURL == nil {
+		return nil, nil
 	}
-	httpReq.Header.Add("Content-Encoding", "snappy")
-	httpReq.Header.Set("Content-Type", "application/x-protobuf")
-	httpReq.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
 
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	httpResp, err := ctxhttp.Do(ctx, c.client, httpReq)
+	httpResp, err := c.sendRequest(ctx, c.labelNamesURL, "label_names", &LabelNamesRequest{Matchers: matchers})
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, err
 	}
 	defer httpResp.Body.Close()
-	if httpResp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("server returned HTTP status %s", httpResp.Status)
+
+	var resp LabelNamesResponse
+	if err := decodeResponse(httpResp, &resp); err != nil {
+		return nil, err
+	}
+	return resp.LabelNames, nil
+}
+
+// LabelValues returns the values of the given label known to the remote
+// endpoint. Results can be restricted to series matching the given matchers
+// and to a time range in milliseconds; no matchers and zero timestamps impose
+// no restriction. It returns nil if no label values URL is configured.
+func (c *Client) LabelValues(ctx context.Context, name string, matchers []*LabelMatcher, startMs, endMs int64) ([]string, error) {
+	values, _, err := c.LabelValuesPage(ctx, name, matchers, startMs, endMs, 0, "")
+	return values, err
+}
+
+// LabelValuesAll works like LabelValues, but gets the values in pages of at
+// most limit values each, until the endpoint returned all of them.
+func (c *Client) LabelValuesAll(ctx context.Context, name string, matchers []*LabelMatcher, startMs, endMs int64, limit int) ([]string, error) {
+	var values []string
+	pageToken := ""
+	for {
+		page, next, err := c.LabelValuesPage(ctx, name, matchers, startMs, endMs, limit, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, page...)
+		if next == "" {
+			return values, nil
+		}
+		if next == pageToken {
+			return nil, fmt.Errorf("label values endpoint returned page token %q for its own page", next)
+		}
+		pageToken = next
+	}
+}
+
+// LabelValuesPage works like LabelValues, but returns at most limit values if
+// limit is positive, starting from the page of a previous call denoted by
+// pageToken; an empty pageToken denotes the first page. The returned token
+// denotes the next page and is empty on the last one.
+func (c *Client) LabelValuesPage(ctx context.Context, name string, matchers []*LabelMatcher, startMs, endMs int64, limit int, pageToken string) (values []string, nextPageToken string, err error) {
+	if c.labelValuesURL == nil {
+		return nil, "", nil
+	}
+	if atomic.LoadInt32(&c.labelValuesDisabled) == 1 {
+		return nil, "", ErrNotImplemented
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req := &LabelValuesRequest{
+		LabelName:        name,
+		Matchers:         matchers,
+		StartTimestampMs: startMs,
+		EndTimestampMs:   endMs,
+		Limit:            int64(limit),
+		PageToken:        pageToken,
+	}
+	httpResp, err := c.sendRequest(ctx, c.labelValuesURL, "label_values", req)
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotImplemented {
+			if c.disableUnimplementedValues {
+				atomic.StoreInt32(&c.labelValuesDisabled, 1)
+			}
+			return nil, "", ErrNotImplemented
+		}
+		return nil, "", err
+	}
+	defer httpResp.Body.Close()
+
+	var resp LabelValuesResponse
+	if err := decodeResponse(httpResp, &resp); err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		// Tokens are only meaningful for paginated requests.
+		resp.NextPageToken = ""
+	}
+	return resp.LabelValues, resp.NextPageToken, nil
+}
+
+// Series returns the label sets of the series matching all given matchers
+// known to the remote endpoint, restricted to a time range in milliseconds;
+// zero timestamps impose no restriction. It returns nil if no series URL is
+// configured.
+func (c *Client) Series(ctx context.Context, matchers []*LabelMatcher, startMs, endMs int64) ([]model.Metric, error) {
+	if c.seriesURL == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req := &SeriesRequest{
+		Matchers:         matchers,
+		StartTimestampMs: startMs,
+		EndTimestampMs:   endMs,
+	}
+	httpResp, err := c.sendRequest(ctx, c.seriesURL, "series", req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp SeriesResponse
+	if err := decodeResponse(httpResp, &resp); err != nil {
+		return nil, err
+	}
+	series := make([]model.Metric, 0, len(resp.Series))
+	for _, s := range resp.Series {
+		series = append(series, labelPairsToMetric(s.Labels))
+	}
+	return series, nil
+}
+
+// Exemplars returns the exemplars of the series matching all given matchers
+// known to the remote endpoint, restricted to a time range in milliseconds;
+// zero timestamps impose no restriction. It returns nil if no exemplars URL
+// is configured, and ErrNotImplemented if the endpoint does not implement
+// exemplar queries.
+func (c *Client) Exemplars(ctx context.Context, matchers []*LabelMatcher, startMs, endMs int64) ([]Exemplar, error) {
+	if c.exemplarsURL == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req := &ExemplarsRequest{
+		Matchers:         matchers,
+		StartTimestampMs: startMs,
+		EndTimestampMs:   endMs,
+	}
+	httpResp, err := c.sendRequest(ctx, c.exemplarsURL, "exemplars", req)
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotImplemented {
+			return nil, ErrNotImplemented
+		}
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp ExemplarsResponse
+	if err := decodeResponse(httpResp, &resp); err != nil {
+		return nil, err
+	}
+	exemplars := make([]Exemplar, 0, len(resp.Exemplars))
+	for _, e := range resp.Exemplars {
+		exemplars = append(exemplars, *e)
+	}
+	return exemplars, nil
+}
+
+// sendReadRequest sends a read request and returns the response if it has a
+// 2xx status code. The caller has to close the response body.
+func (c *Client) sendReadRequest(ctx context.Context, req *ReadRequest) (*http.Response, error) {
+	return c.sendRequest(ctx, c.readURL, "read", req)
+}
+
+// sendRequest sends a snappy-compressed protobuf request to the given URL and
+// returns the response if it has a 2xx status code. The caller has to close
+// the response body. The operation names the request in metrics and traces.
+func (c *Client) sendRequest(ctx context.Context, u *config.URL, operation string, req proto.Message) (*http.Response, error) {
+	span, ctx := c.startSpan(ctx, operation, u)
+	httpResp, err := c.doRequest(ctx, u, operation, req)
+	finishSpan(span, err)
+	return httpResp, err
+}
+
+// doRequest implements sendRequest within the span in ctx.
+func (c *Client) doRequest(ctx context.Context, u *config.URL, operation string, req proto.Message) (*http.Response, error) {
+	if err := c.checkOpen(); err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal request: %v", err)
 	}
 
-	compressed, err = ioutil.ReadAll(httpResp.Body)
+	compressed := snappy.Encode(nil, data)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewBuffer(compressed))
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		return nil, fmt.Errorf("unable to create request: %v", err)
+	}
+	httpReq.Header.Add("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if c.acceptEncoding != "" {
+		httpReq.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+	if tenant, ok := tenantFromContext(ctx); ok {
+		httpReq.Header.Set(TenantHeader, tenant)
 	}
+	c.injectSpan(ctx, httpReq)
 
-	uncompressed, err := snappy.Decode(nil, compressed)
+	begin := c.clock.Now()
+	httpResp, err := c.do(ctx, u, httpReq)
+	c.metrics.observeRequest(u, operation, c.clock.Now().Sub(begin), httpResp, err)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		c.logRequest(u, operation, len(compressed), begin, nil, err)
+		// Report cancellation and deadlines as such rather than as the
+		// transport error they surface as.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, recoverableError{fmt.Errorf("error sending request: %v", err)}
+	}
+	observeResponse(ctx, httpResp)
+	if httpResp.StatusCode/100 != 2 {
+		defer httpResp.Body.Close()
+		httpErr := c.newHTTPError(httpResp, false)
+		c.logRequest(u, operation, len(compressed), begin, httpResp, httpErr)
+		if c.recoverable(httpErr, httpResp.StatusCode/100 == 5) {
+			return nil, recoverableError{httpErr}
+		}
+		return nil, httpErr
 	}
+	c.logRequest(u, operation, len(compressed), begin, httpResp, nil)
+	return httpResp, nil
+}
 
+// readResult decodes a snappy-compressed ReadResponse holding the results
+// of the given number of queries and returns the first result.
+func readResult(httpResp *http.Response, queries int) (*QueryResult, error) {
 	var resp ReadResponse
-	err = proto.Unmarshal(uncompressed, &resp)
+	if err := decodeResponse(httpResp, &resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Results) != queries {
+		return nil, fmt.Errorf("responses: want %d, got %d", queries, len(resp.Results))
+	}
+
+	return resp.Results[0], nil
+}
+
+// decodeResponse reads a compressed protobuf response into resp, decoding it
+// according to its Content-Encoding, snappy if it has none.
+func decodeResponse(httpResp *http.Response, resp proto.Message) error {
+	compressed, err := ioutil.ReadAll(httpResp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("unable to unmarshal response body: %v", err)
+		return fmt.Errorf("error reading response: %v", err)
 	}
 
-	if len(resp.Results) != len(req.Queries) {
-		return nil, fmt.Errorf("responses: want %d, got %d", len(req.Queries), len(resp.Results))
+	uncompressed, err := decompress(httpResp.Header.Get("Content-Encoding"), compressed, 0)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
 	}
 
-	return matrixFromProto(resp.Results[0].Timeseries), nil
+	if err := proto.Unmarshal(uncompressed, resp); err != nil {
+		return fmt.Errorf("unable to unmarshal response body: %v", err)
+	}
+	return nil
 }
 
 func labelMatchersToProto(matchers metric.LabelMatchers) []*LabelMatcher {
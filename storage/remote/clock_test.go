@@ -0,0 +1,146 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// fakeClock only moves forward when advanced. Every wait started with After
+// is announced on waits.
+type fakeClock struct {
+	mtx     sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	waits   chan time.Duration
+}
+
+type fakeWaiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+		waits: make(chan time.Duration, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{until: c.now.Add(d), ch: ch})
+	c.waits <- d
+	return ch
+}
+
+// advance moves the clock forward, ending the waits it passes.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.until.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+func TestStoreRetriesFakeClock(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) <= 3 {
+				http.Error(w, "test error", http.StatusServiceUnavailable)
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:              &config.URL{URL: serverURL},
+		Timeout:          model.Duration(time.Second),
+		RetryMaxAttempts: 4,
+		// Far too long to be waited for for real.
+		RetryMinBackoff: model.Duration(time.Hour),
+		RetryMaxBackoff: model.Duration(2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock()
+	c.clock = clock
+
+	done := make(chan error)
+	go func() {
+		done <- c.Store(context.Background(), testWriteRequest())
+	}()
+
+	for i, want := range []time.Duration{time.Hour, 2 * time.Hour, 2 * time.Hour} {
+		select {
+		case got := <-clock.waits:
+			if got != want {
+				t.Fatalf("%d. Unexpected backoff; want %s, got %s", i, want, got)
+			}
+		case err := <-done:
+			t.Fatalf("%d. Store returned before backing off: %v", i, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d. Timed out waiting for backoff", i)
+		}
+		clock.advance(want)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Store")
+	}
+	if got := atomic.LoadInt32(&calls); got != 4 {
+		t.Fatalf("Unexpected number of requests; want 4, got %d", got)
+	}
+	if want := clock.Now(); !c.LastSuccessTime().Equal(want) {
+		t.Fatalf("Unexpected last success time; want %s, got %s", want, c.LastSuccessTime())
+	}
+}
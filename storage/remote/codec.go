@@ -0,0 +1,486 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms supported for remote storage request bodies. The
+// names double as the values of the Content-Encoding header, except for
+// NoCompression, which is sent as identityEncoding.
+const (
+	SnappyCompression = "snappy"
+	ZstdCompression   = "zstd"
+	// GzipCompression is meant for legacy servers that do not support
+	// snappy.
+	GzipCompression = "gzip"
+	// NoCompression sends request bodies as they are, such as to debug
+	// wire captures. Most servers reject such requests.
+	NoCompression = "none"
+)
+
+// identityEncoding is the Content-Encoding of uncompressed request bodies,
+// as the lack of one stands for snappy.
+const identityEncoding = "identity"
+
+// The zstd encoder and decoder are safe for concurrent use of EncodeAll and
+// DecodeAll, so a single instance of each is shared by all clients. Encoders
+// for other than the default level are created as they are needed.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+
+	zstdEncodersMtx sync.Mutex
+	zstdEncoders    = map[zstd.EncoderLevel]*zstd.Encoder{}
+)
+
+// Bounds of the zstd compression levels.
+const (
+	minZstdLevel = 1
+	maxZstdLevel = 22
+)
+
+// zstdEncoderFor returns the shared encoder for the given zstd compression
+// level, zero being the default one.
+func zstdEncoderFor(level int) *zstd.Encoder {
+	if level == 0 {
+		return zstdEncoder
+	}
+	l := zstd.EncoderLevelFromZstd(level)
+
+	zstdEncodersMtx.Lock()
+	defer zstdEncodersMtx.Unlock()
+	enc, ok := zstdEncoders[l]
+	if !ok {
+		enc, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(l))
+		zstdEncoders[l] = enc
+	}
+	return enc
+}
+
+// validateCompression returns an error if the given compression algorithm is
+// not supported. The empty string selects the default, snappy.
+func validateCompression(compression string) error {
+	switch compression {
+	case "", SnappyCompression, ZstdCompression, GzipCompression, NoCompression:
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// validateEncoding returns an error if the Content-Encoding of a received
+// request is not one decompress supports.
+func validateEncoding(encoding string) error {
+	if encoding == identityEncoding {
+		return nil
+	}
+	return validateCompression(encoding)
+}
+
+// validateCompressionLevel returns an error if level is not a level of the
+// given compression algorithm. Zero selects the default level of any of
+// them. Snappy has no levels.
+func validateCompressionLevel(compression string, level int) error {
+	if level == 0 {
+		return nil
+	}
+	switch compression {
+	case ZstdCompression:
+		if level < minZstdLevel || level > maxZstdLevel {
+			return fmt.Errorf("zstd compression level must be between %d and %d, got %d", minZstdLevel, maxZstdLevel, level)
+		}
+	case GzipCompression:
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("gzip compression level must be between %d and %d, got %d", gzip.HuffmanOnly, gzip.BestCompression, level)
+		}
+	case NoCompression:
+		return fmt.Errorf("compression level %d set, but compression is disabled", level)
+	default:
+		return fmt.Errorf("compression level %d set, but snappy compression has no levels", level)
+	}
+	return nil
+}
+
+// compress encodes data with the given compression algorithm at its default
+// level.
+func compress(compression string, data []byte) ([]byte, error) {
+	return compressTo(compression, 0, nil, data)
+}
+
+// compressTo encodes data with the given compression algorithm and level,
+// zero being the default one, reusing the capacity of dst if it is large
+// enough.
+func compressTo(compression string, level int, dst, data []byte) ([]byte, error) {
+	switch compression {
+	case "", SnappyCompression:
+		return snappy.Encode(dst[:cap(dst)], data), nil
+	case ZstdCompression:
+		return zstdEncoderFor(level).EncodeAll(data, dst[:0]), nil
+	case NoCompression:
+		// Copy, as data may be reused while the result is not.
+		return append(dst[:0], data...), nil
+	case GzipCompression:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		buf := bytes.NewBuffer(dst[:0])
+		w, err := gzip.NewWriterLevel(buf, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// writeBuffers hold the buffers a write request is encoded into. They are
+// pooled, so that the large buffers needed for big batches are reused rather
+// than allocated anew for every request.
+type writeBuffers struct {
+	proto      *proto.Buffer
+	compressed []byte
+}
+
+var writeBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &writeBuffers{proto: proto.NewBuffer(nil)}
+	},
+}
+
+// encodeWriteRequest marshals and compresses a write request of any protocol
+// version into bufs. The returned slices are backed by bufs and only valid
+// until they are reused.
+func encodeWriteRequest(req proto.Message, compression string, level int, bufs *writeBuffers) (data, compressed []byte, err error) {
+	bufs.proto.Reset()
+	if err := bufs.proto.Marshal(req); err != nil {
+		return nil, nil, err
+	}
+	data = bufs.proto.Bytes()
+
+	compressed, err = compressTo(compression, level, bufs.compressed, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	bufs.compressed = compressed
+	return data, compressed, nil
+}
+
+// errDecompressedTooLarge is returned by decompress for data decompressing
+// to more than the given maximum.
+var errDecompressedTooLarge = errors.New("decompressed data too large")
+
+// decompress decodes data according to the given Content-Encoding. An empty
+// encoding is treated as snappy, as older senders did not always set it. If
+// max is positive, data decompressing to more than max bytes fails with
+// errDecompressedTooLarge, without decompressing more than that.
+func decompress(encoding string, data []byte, max int) ([]byte, error) {
+	switch encoding {
+	case "", SnappyCompression:
+		// Snappy blocks start with their decoded length.
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if max > 0 && n > max {
+			return nil, errDecompressedTooLarge
+		}
+		return snappy.Decode(nil, data)
+	case ZstdCompression:
+		if max <= 0 {
+			return zstdDecoder.DecodeAll(data, nil)
+		}
+		d, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return readAllLimited(d, max)
+	case GzipCompression:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return readAllLimited(r, max)
+	case identityEncoding, NoCompression:
+		if max > 0 && len(data) > max {
+			return nil, errDecompressedTooLarge
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// readAllLimited reads r to the end, failing with errDecompressedTooLarge
+// once it read more than max bytes, if max is positive.
+func readAllLimited(r io.Reader, max int) ([]byte, error) {
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > max {
+		return nil, errDecompressedTooLarge
+	}
+	return data, nil
+}
+
+// MaxCompressedRequestBytes is the maximum size of the compressed request
+// bodies read by the decode helpers.
+const MaxCompressedRequestBytes = 32 << 20
+
+// DefaultMaxDecompressedBytes is the default maximum size of request bodies
+// decoded by the decode helpers, once decompressed.
+const DefaultMaxDecompressedBytes = 32 << 20
+
+type decodeOptions struct {
+	maxDecompressedBytes int
+}
+
+// DecodeOption changes how the decode helpers decode requests.
+type DecodeOption func(*decodeOptions)
+
+// MaxDecompressedBytes makes the decode helpers reject requests whose bodies
+// decompress to more than n bytes with a 413 DecodeError, instead of
+// DefaultMaxDecompressedBytes. A non-positive n lifts the limit.
+func MaxDecompressedBytes(n int) DecodeOption {
+	return func(o *decodeOptions) {
+		o.maxDecompressedBytes = n
+	}
+}
+
+// DecodeError is returned by the decode helpers for requests that cannot be
+// decoded.
+type DecodeError struct {
+	// StatusCode is the HTTP status to respond to the request with, such
+	// as 400 for malformed bodies or 413 for oversized ones.
+	StatusCode int
+	Err        error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeWriteRequest reads a compressed write request from an HTTP request
+// body, picking the decompressor based on the Content-Encoding header.
+// Relative timestamps are made absolute again. Requests that cannot be
+// decoded fail with a DecodeError.
+func DecodeWriteRequest(r *http.Request, opts ...DecodeOption) (*WriteRequest, error) {
+	var req WriteRequest
+	if err := decodeRequest(r, &req, opts...); err != nil {
+		return nil, err
+	}
+	if r.Header.Get(TimestampEncodingHeader) == RelativeTimestamps {
+		absoluteTimestamps(&req)
+	}
+	return &req, nil
+}
+
+// EncodeWriteResponse responds to a decoded write request. Without rejected
+// series, the response is an empty 204. Otherwise, the response carries
+// resp with the WriteStatsContentType, with status 200 if some samples were
+// accepted, as reported by clients with ParsePartialSuccess, or 400 if none
+// were.
+func EncodeWriteResponse(resp *WriteResponse, w http.ResponseWriter) error {
+	if resp == nil || len(resp.Rejected) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", WriteStatsContentType)
+	if resp.Accepted > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// DecodeWriteRequestV2 reads a compressed remote write 2.0 request from an
+// HTTP request body, picking the decompressor based on the Content-Encoding
+// header.
+func DecodeWriteRequestV2(r *http.Request, opts ...DecodeOption) (*WriteRequestV2, error) {
+	var req WriteRequestV2
+	if err := decodeRequest(r, &req, opts...); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// DecodeLabelNamesRequest reads a compressed label names request from an
+// HTTP request body.
+func DecodeLabelNamesRequest(r *http.Request, opts ...DecodeOption) (*LabelNamesRequest, error) {
+	var req LabelNamesRequest
+	if err := decodeRequest(r, &req, opts...); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// EncodeLabelNamesResponse writes a snappy-compressed label names response to
+// an HTTP response writer.
+func EncodeLabelNamesResponse(resp *LabelNamesResponse, w http.ResponseWriter) error {
+	return encodeResponse(resp, w)
+}
+
+// DecodeLabelValuesRequest reads a compressed label values request from an
+// HTTP request body.
+func DecodeLabelValuesRequest(r *http.Request, opts ...DecodeOption) (*LabelValuesRequest, error) {
+	var req LabelValuesRequest
+	if err := decodeRequest(r, &req, opts...); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// EncodeLabelValuesResponse writes a snappy-compressed label values response
+// to an HTTP response writer.
+func EncodeLabelValuesResponse(resp *LabelValuesResponse, w http.ResponseWriter) error {
+	return encodeResponse(resp, w)
+}
+
+// DecodeSeriesRequest reads a compressed series request from an HTTP request
+// body.
+func DecodeSeriesRequest(r *http.Request, opts ...DecodeOption) (*SeriesRequest, error) {
+	var req SeriesRequest
+	if err := decodeRequest(r, &req, opts...); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// EncodeSeriesResponse writes a snappy-compressed series response to an HTTP
+// response writer.
+func EncodeSeriesResponse(resp *SeriesResponse, w http.ResponseWriter) error {
+	return encodeResponse(resp, w)
+}
+
+// DecodeExemplarsRequest reads a compressed exemplars request from an HTTP
+// request body.
+func DecodeExemplarsRequest(r *http.Request, opts ...DecodeOption) (*ExemplarsRequest, error) {
+	var req ExemplarsRequest
+	if err := decodeRequest(r, &req, opts...); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// EncodeExemplarsResponse writes a snappy-compressed exemplars response to an
+// HTTP response writer.
+func EncodeExemplarsResponse(resp *ExemplarsResponse, w http.ResponseWriter) error {
+	return encodeResponse(resp, w)
+}
+
+// decodeRequest reads a compressed protobuf message from an HTTP request
+// body, picking the decompressor based on the Content-Encoding header.
+func decodeRequest(r *http.Request, pb proto.Message, opts ...DecodeOption) error {
+	o := decodeOptions{maxDecompressedBytes: DefaultMaxDecompressedBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	compressed, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxCompressedRequestBytes+1))
+	if err != nil {
+		return &DecodeError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("reading request body: %s", err)}
+	}
+	if len(compressed) > MaxCompressedRequestBytes {
+		return &DecodeError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Err:        fmt.Errorf("request body exceeds %d bytes", MaxCompressedRequestBytes),
+		}
+	}
+
+	encoding := r.Header.Get("Content-Encoding")
+	if err := validateEncoding(encoding); err != nil {
+		return &DecodeError{StatusCode: http.StatusUnsupportedMediaType, Err: err}
+	}
+	return decodeCompressed(encoding, compressed, pb, o)
+}
+
+// decodeCompressed decompresses and unmarshals a request body, or a frame of
+// a stream, whose encoding was validated already.
+func decodeCompressed(encoding string, compressed []byte, pb proto.Message, o decodeOptions) error {
+	reqBuf, err := decompress(encoding, compressed, o.maxDecompressedBytes)
+	if err == errDecompressedTooLarge {
+		return &DecodeError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Err:        fmt.Errorf("request body exceeds %d bytes once decompressed", o.maxDecompressedBytes),
+		}
+	}
+	if err != nil {
+		return &DecodeError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("decompressing %s request body: %s", encodingName(encoding), err)}
+	}
+
+	if err := proto.Unmarshal(reqBuf, pb); err != nil {
+		return &DecodeError{StatusCode: http.StatusBadRequest, Err: fmt.Errorf("decoding request: %s", err)}
+	}
+	return nil
+}
+
+// encodingName returns the name of a Content-Encoding for error messages.
+func encodingName(encoding string) string {
+	if encoding == "" {
+		return SnappyCompression
+	}
+	return encoding
+}
+
+// encodeResponse writes a snappy-compressed protobuf message to an HTTP
+// response writer.
+func encodeResponse(pb proto.Message, w http.ResponseWriter) error {
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+
+	_, err = w.Write(snappy.Encode(nil, data))
+	return err
+}
@@ -0,0 +1,544 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestStoreCompressionRoundTrip(t *testing.T) {
+	samples := model.Samples{
+		{
+			Metric:    model.Metric{model.MetricNameLabel: "test_metric"},
+			Value:     1.5,
+			Timestamp: 1234,
+		},
+	}
+	want := &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{
+				Labels:  []*LabelPair{{Name: "__name__", Value: "test_metric"}},
+				Samples: []*Sample{{Value: 1.5, TimestampMs: 1234}},
+			},
+		},
+	}
+
+	for _, compression := range []string{"", SnappyCompression, ZstdCompression, GzipCompression, NoCompression} {
+		var (
+			got      *WriteRequest
+			encoding string
+		)
+		server := httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				encoding = r.Header.Get("Content-Encoding")
+				got, err = DecodeWriteRequest(r)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+			}),
+		)
+
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			panic(err)
+		}
+
+		c, err := NewClient(0, &ClientConfig{
+			URL:         &config.URL{URL: serverURL},
+			Timeout:     model.Duration(time.Second),
+			Compression: compression,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Store(context.Background(), toWriteRequest(samples)); err != nil {
+			t.Fatalf("%q: unexpected error: %v", compression, err)
+		}
+		wantEncoding := compression
+		switch wantEncoding {
+		case "":
+			wantEncoding = SnappyCompression
+		case NoCompression:
+			wantEncoding = identityEncoding
+		}
+		if encoding != wantEncoding {
+			t.Fatalf("%q: unexpected Content-Encoding; want %q, got %q", compression, wantEncoding, encoding)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: unexpected write request; want %v, got %v", compression, want, got)
+		}
+
+		server.Close()
+	}
+}
+
+func TestNewClientInvalidCompression(t *testing.T) {
+	_, err := NewClient(0, &ClientConfig{
+		URL:         &config.URL{URL: &url.URL{Scheme: "http", Host: "localhost:9201"}},
+		Compression: "lz4",
+	})
+	if err == nil {
+		t.Fatal("expected error for unsupported compression")
+	}
+}
+
+func TestStoreNoCompression(t *testing.T) {
+	var (
+		got      []*WriteRequest
+		encoding []string
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding = append(encoding, r.Header["Content-Encoding"]...)
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			var req WriteRequest
+			if err := proto.Unmarshal(body, &req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			got = append(got, &req)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:         &config.URL{URL: serverURL},
+		Timeout:     model.Duration(time.Second),
+		Compression: NoCompression,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send requests of different sizes, as the encoding buffers are reused.
+	var want []*WriteRequest
+	for i := 1; i <= 3; i++ {
+		samples := make(model.Samples, 0, 4-i)
+		for j := 0; j < 4-i; j++ {
+			samples = append(samples, &model.Sample{
+				Metric: model.Metric{model.MetricNameLabel: model.LabelValue(fmt.Sprintf("test_metric_%d_%d", i, j))},
+				Value:  model.SampleValue(j),
+			})
+		}
+		req := toWriteRequest(samples)
+		if err := c.Store(context.Background(), req); err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		want = append(want, req)
+	}
+	if want := []string{identityEncoding, identityEncoding, identityEncoding}; !reflect.DeepEqual(encoding, want) {
+		t.Fatalf("Unexpected Content-Encoding headers; want %v, got %v", want, encoding)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected write requests; want %v, got %v", want, got)
+	}
+}
+
+func TestStoreCompressionBySize(t *testing.T) {
+	var (
+		got       []*WriteRequest
+		encodings []string
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encodings = append(encodings, r.Header.Get("Content-Encoding"))
+			req, err := DecodeWriteRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			got = append(got, req)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:               &config.URL{URL: serverURL},
+		Timeout:           model.Duration(time.Second),
+		CompressionBySize: &CompressionBySize{Threshold: 1000},
+		CompressionLevel:  3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []*WriteRequest
+	for _, n := range []int{1, 100} {
+		samples := make(model.Samples, 0, n)
+		for i := 0; i < n; i++ {
+			samples = append(samples, &model.Sample{
+				Metric: model.Metric{model.MetricNameLabel: model.LabelValue(fmt.Sprintf("test_metric_%d", i))},
+				Value:  model.SampleValue(i),
+			})
+		}
+		req := toWriteRequest(samples)
+		if err := c.Store(context.Background(), req); err != nil {
+			t.Fatalf("%d samples: unexpected error: %v", n, err)
+		}
+		want = append(want, req)
+	}
+
+	wantEncodings := []string{SnappyCompression, ZstdCompression}
+	if !reflect.DeepEqual(encodings, wantEncodings) {
+		t.Fatalf("Unexpected Content-Encodings; want %v, got %v", wantEncodings, encodings)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected write requests; want %v, got %v", want, got)
+	}
+
+	for _, conf := range []*CompressionBySize{
+		{Threshold: 0},
+		{Threshold: 1000, Large: "brotli"},
+	} {
+		if _, err := NewClient(0, &ClientConfig{URL: &config.URL{URL: serverURL}, CompressionBySize: conf}); err == nil {
+			t.Fatalf("%+v: expected error", conf)
+		}
+	}
+}
+
+func TestStoreCompressionLevel(t *testing.T) {
+	samples := make(model.Samples, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, &model.Sample{
+			Metric:    model.Metric{model.MetricNameLabel: model.LabelValue(fmt.Sprintf("test_metric_%d", i%37)), "instance": model.LabelValue(fmt.Sprintf("host-%d:9090", i%13))},
+			Value:     model.SampleValue(i * i % 101),
+			Timestamp: model.Time(1234 + i*15000),
+		})
+	}
+	req := toWriteRequest(samples)
+
+	var (
+		size int
+		got  *WriteRequest
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			size = int(r.ContentLength)
+			var err error
+			got, err = DecodeWriteRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, test := range []struct {
+		compression string
+		fast, best  int
+	}{
+		{compression: ZstdCompression, fast: 1, best: 19},
+		{compression: GzipCompression, fast: 1, best: 9},
+	} {
+		sizes := map[int]int{}
+		for _, level := range []int{test.fast, test.best} {
+			c, err := NewClient(0, &ClientConfig{
+				URL:              &config.URL{URL: serverURL},
+				Timeout:          model.Duration(time.Second),
+				Compression:      test.compression,
+				CompressionLevel: level,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Store(context.Background(), req); err != nil {
+				t.Fatalf("%s level %d: unexpected error: %v", test.compression, level, err)
+			}
+			if !reflect.DeepEqual(got, req) {
+				t.Fatalf("%s level %d: unexpected write request", test.compression, level)
+			}
+			sizes[level] = size
+		}
+		if sizes[test.best] >= sizes[test.fast] {
+			t.Fatalf("%s: level %d not smaller than level %d: %v", test.compression, test.best, test.fast, sizes)
+		}
+	}
+}
+
+func TestNewClientInvalidCompressionLevel(t *testing.T) {
+	u := &config.URL{URL: &url.URL{Scheme: "http", Host: "localhost:9201"}}
+	for i, test := range []struct {
+		compression string
+		level       int
+		wantErr     string
+	}{
+		{compression: ZstdCompression, level: 0},
+		{compression: ZstdCompression, level: 22},
+		{compression: ZstdCompression, level: 23, wantErr: "zstd compression level must be between 1 and 22"},
+		{compression: ZstdCompression, level: -1, wantErr: "zstd compression level must be between 1 and 22"},
+		{compression: GzipCompression, level: -2},
+		{compression: GzipCompression, level: 10, wantErr: "gzip compression level must be between -2 and 9"},
+		{compression: "", level: 1, wantErr: "snappy compression has no levels"},
+		{compression: SnappyCompression, level: 0},
+		{compression: NoCompression, level: 1, wantErr: "compression is disabled"},
+	} {
+		_, err := NewClient(0, &ClientConfig{URL: u, Compression: test.compression, CompressionLevel: test.level})
+		if test.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%d. Unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Fatalf("%d. Unexpected error; want %q, got %v", i, test.wantErr, err)
+		}
+	}
+}
+
+func TestDecodeLabelValuesRequestGzip(t *testing.T) {
+	want := &LabelValuesRequest{LabelName: "job"}
+	data, err := proto.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := compress(GzipCompression, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest("POST", "http://localhost/label_values", bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Encoding", GzipCompression)
+
+	got, err := DecodeLabelValuesRequest(r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected request; want %v, got %v", want, got)
+	}
+}
+
+func TestDecodeWriteRequestErrors(t *testing.T) {
+	valid, err := proto.Marshal(&WriteRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body       []byte
+		encoding   string
+		wantStatus int
+	}{
+		{
+			// Bad snappy framing.
+			body:       []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x02},
+			encoding:   SnappyCompression,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			// Decompresses fine, but is not a write request.
+			body:       snappy.Encode(nil, []byte{0xff, 0xff}),
+			encoding:   SnappyCompression,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			body:       snappy.Encode(nil, valid),
+			encoding:   "lz4",
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			body:       make([]byte, MaxCompressedRequestBytes+1),
+			encoding:   SnappyCompression,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for i, test := range tests {
+		r, err := http.NewRequest("POST", "http://localhost/write", bytes.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Encoding", test.encoding)
+
+		_, err = DecodeWriteRequest(r)
+		decodeErr, ok := err.(*DecodeError)
+		if !ok {
+			t.Fatalf("%d. Expected DecodeError, got %v", i, err)
+		}
+		if decodeErr.StatusCode != test.wantStatus {
+			t.Fatalf("%d. Unexpected status; want %d, got %d (%v)", i, test.wantStatus, decodeErr.StatusCode, err)
+		}
+	}
+}
+
+func TestDecodeMaxDecompressedBytes(t *testing.T) {
+	req := &LabelValuesRequest{LabelName: strings.Repeat("a", 2048)}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, encoding := range []string{SnappyCompression, ZstdCompression, GzipCompression} {
+		compressed, err := compress(encoding, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range []struct {
+			max        int
+			wantStatus int
+		}{
+			{max: len(data), wantStatus: http.StatusOK},
+			{max: len(data) - 1, wantStatus: http.StatusRequestEntityTooLarge},
+			{max: 0, wantStatus: http.StatusOK},
+		} {
+			r, err := http.NewRequest("POST", "http://localhost/label_values", bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Content-Encoding", encoding)
+
+			got, err := DecodeLabelValuesRequest(r, MaxDecompressedBytes(test.max))
+			if test.wantStatus == http.StatusOK {
+				if err != nil {
+					t.Fatalf("%s, %d: unexpected error: %v", encoding, test.max, err)
+				}
+				if !reflect.DeepEqual(got, req) {
+					t.Fatalf("%s, %d: unexpected request", encoding, test.max)
+				}
+				continue
+			}
+			if decodeErr, ok := err.(*DecodeError); !ok || decodeErr.StatusCode != test.wantStatus {
+				t.Fatalf("%s, %d: expected DecodeError with status %d, got %v", encoding, test.max, test.wantStatus, err)
+			}
+		}
+	}
+
+	// A snappy block claiming to decompress to 500MB is rejected by the
+	// default limit before anything is allocated for it.
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], 500<<20)
+	r, err := http.NewRequest("POST", "http://localhost/write", bytes.NewReader(append(header[:n], 0, 1, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecodeWriteRequest(r)
+	if decodeErr, ok := err.(*DecodeError); !ok || decodeErr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected DecodeError with status 413, got %v", err)
+	}
+}
+
+func TestEncodeWriteResponse(t *testing.T) {
+	rejected := []RejectedSeries{{Series: `up{job="a"}`, Reason: "out_of_order"}}
+	tests := []struct {
+		resp       *WriteResponse
+		wantStatus int
+		wantStats  bool
+	}{
+		{resp: nil, wantStatus: http.StatusNoContent},
+		{resp: &WriteResponse{Accepted: 3}, wantStatus: http.StatusNoContent},
+		{resp: &WriteResponse{Accepted: 3, Rejected: rejected}, wantStatus: http.StatusOK, wantStats: true},
+		{resp: &WriteResponse{Rejected: rejected}, wantStatus: http.StatusBadRequest, wantStats: true},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		if err := EncodeWriteResponse(test.resp, w); err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		if w.Code != test.wantStatus {
+			t.Fatalf("%d. Unexpected status; want %d, got %d", i, test.wantStatus, w.Code)
+		}
+		if isWriteStats(w.Header().Get("Content-Type")) != test.wantStats {
+			t.Fatalf("%d. Unexpected Content-Type %q", i, w.Header().Get("Content-Type"))
+		}
+		if !test.wantStats {
+			continue
+		}
+		resp := &http.Response{StatusCode: w.Code, Header: w.Header(), Body: ioutil.NopCloser(w.Body)}
+		partial := parsePartialWrite(resp)
+		if want := (&PartialWriteError{Accepted: test.resp.Accepted, Rejected: rejected}); !reflect.DeepEqual(partial, want) {
+			t.Fatalf("%d. Unexpected parsed response; want %v, got %v", i, want, partial)
+		}
+	}
+}
+
+func BenchmarkEncodeWriteRequest(b *testing.B) {
+	samples := make(model.Samples, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, &model.Sample{
+			Metric: model.Metric{
+				model.MetricNameLabel: "test_metric",
+				"instance":            model.LabelValue(fmt.Sprintf("instance-%d", i)),
+			},
+			Value:     model.SampleValue(i),
+			Timestamp: model.Time(i),
+		})
+	}
+	req := toWriteRequest(samples)
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := proto.Marshal(req)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := compress(SnappyCompression, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bufs := writeBufferPool.Get().(*writeBuffers)
+			if _, _, err := encodeWriteRequest(req, SnappyCompression, 0, bufs); err != nil {
+				b.Fatal(err)
+			}
+			writeBufferPool.Put(bufs)
+		}
+	})
+}
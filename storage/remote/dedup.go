@@ -0,0 +1,130 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// dedupKey identifies a sample, or a histogram sample, of a series.
+type dedupKey struct {
+	fp        model.Fingerprint
+	timestamp int64
+	histogram bool
+}
+
+type dedupEntry struct {
+	key    dedupKey
+	sentAt time.Time
+}
+
+// deduper remembers the samples sent within a sliding window, so that the
+// same samples written again, such as by the other replica of an HA pair
+// sharing the client, can be dropped. It is best-effort: samples are only
+// remembered once sent, so duplicates sent concurrently both get through.
+type deduper struct {
+	window time.Duration
+
+	mtx  sync.Mutex
+	seen map[dedupKey]time.Time
+	// Oldest first, for expiring the seen samples.
+	entries []dedupEntry
+}
+
+func newDeduper(window time.Duration) *deduper {
+	if window <= 0 {
+		return nil
+	}
+	return &deduper{window: window, seen: map[dedupKey]time.Time{}}
+}
+
+// expire forgets the samples sent longer than the window before now. The
+// mutex must be held.
+func (d *deduper) expire(now time.Time) {
+	i := 0
+	for ; i < len(d.entries); i++ {
+		e := d.entries[i]
+		if now.Sub(e.sentAt) < d.window {
+			break
+		}
+		// Only forget the sample if it was not sent again since.
+		if d.seen[e.key].Equal(e.sentAt) {
+			delete(d.seen, e.key)
+		}
+	}
+	d.entries = d.entries[i:]
+}
+
+// filter returns a write request without the samples sent within the window,
+// and the number of samples dropped. Series left without samples are dropped
+// altogether. The given request is left untouched.
+func (d *deduper) filter(req *WriteRequest, now time.Time) (*WriteRequest, int) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.expire(now)
+
+	filtered := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(req.Timeseries)),
+		Metadata:   req.Metadata,
+	}
+	dropped := 0
+	for _, ts := range req.Timeseries {
+		fp := labelPairsToMetric(ts.Labels).Fingerprint()
+		cp := *ts
+		cp.Samples = make([]*Sample, 0, len(ts.Samples))
+		for _, s := range ts.Samples {
+			if _, ok := d.seen[dedupKey{fp: fp, timestamp: s.TimestampMs}]; ok {
+				dropped++
+				continue
+			}
+			cp.Samples = append(cp.Samples, s)
+		}
+		cp.Histograms = make([]*Histogram, 0, len(ts.Histograms))
+		for _, h := range ts.Histograms {
+			if _, ok := d.seen[dedupKey{fp: fp, timestamp: h.TimestampMs, histogram: true}]; ok {
+				dropped++
+				continue
+			}
+			cp.Histograms = append(cp.Histograms, h)
+		}
+		if len(cp.Samples) == 0 && len(cp.Histograms) == 0 && (len(ts.Samples) > 0 || len(ts.Histograms) > 0) {
+			continue
+		}
+		filtered.Timeseries = append(filtered.Timeseries, &cp)
+	}
+	return filtered, dropped
+}
+
+// record remembers the samples of a write request as sent at now.
+func (d *deduper) record(req *WriteRequest, now time.Time) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	add := func(key dedupKey) {
+		d.seen[key] = now
+		d.entries = append(d.entries, dedupEntry{key: key, sentAt: now})
+	}
+	for _, ts := range req.Timeseries {
+		fp := labelPairsToMetric(ts.Labels).Fingerprint()
+		for _, s := range ts.Samples {
+			add(dedupKey{fp: fp, timestamp: s.TimestampMs})
+		}
+		for _, h := range ts.Histograms {
+			add(dedupKey{fp: fp, timestamp: h.TimestampMs, histogram: true})
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestClientDedup(t *testing.T) {
+	var (
+		mtx  sync.Mutex
+		sent []string
+		fail bool
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := DecodeWriteRequest(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			if fail {
+				http.Error(w, "test error", http.StatusBadRequest)
+				return
+			}
+			for _, ts := range req.Timeseries {
+				m := labelPairsToMetric(ts.Labels)
+				for _, s := range ts.Samples {
+					sent = append(sent, model.Sample{Metric: m, Value: model.SampleValue(s.Value), Timestamp: model.Time(s.TimestampMs)}.String())
+				}
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:         &config.URL{URL: serverURL},
+		Timeout:     model.Duration(time.Second),
+		DedupWindow: model.Duration(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock()
+	c.clock = clock
+
+	series := func(job string, timestamps ...int64) *TimeSeries {
+		ts := &TimeSeries{Labels: []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "job", Value: job}}}
+		for _, t := range timestamps {
+			ts.Samples = append(ts.Samples, &Sample{Value: 1, TimestampMs: t})
+		}
+		return ts
+	}
+
+	tests := []struct {
+		series     []*TimeSeries
+		advance    time.Duration
+		fail       bool
+		want       []string
+		duplicates int
+	}{
+		{
+			series: []*TimeSeries{series("a", 1, 2)},
+			want:   []string{`up{job="a"} => 1 @[0.001]`, `up{job="a"} => 1 @[0.002]`},
+		},
+		{
+			// Later timestamps and other series pass.
+			series:     []*TimeSeries{series("a", 2, 3), series("b", 2)},
+			want:       []string{`up{job="a"} => 1 @[0.003]`, `up{job="b"} => 1 @[0.002]`},
+			duplicates: 1,
+		},
+		{
+			series:     []*TimeSeries{series("a", 1), series("b", 2)},
+			advance:    30 * time.Second,
+			duplicates: 2,
+		},
+		{
+			// Samples that failed to be sent are not remembered.
+			series: []*TimeSeries{series("c", 1)},
+			fail:   true,
+		},
+		{
+			series: []*TimeSeries{series("c", 1)},
+			want:   []string{`up{job="c"} => 1 @[0.001]`},
+		},
+		{
+			// Samples are forgotten once the window passed.
+			series:     []*TimeSeries{series("a", 1), series("c", 1)},
+			advance:    40 * time.Second,
+			want:       []string{`up{job="a"} => 1 @[0.001]`},
+			duplicates: 1,
+		},
+	}
+
+	for i, test := range tests {
+		clock.advance(test.advance)
+		mtx.Lock()
+		sent, fail = nil, test.fail
+		mtx.Unlock()
+
+		req := &WriteRequest{Timeseries: test.series}
+		stats, err := c.StoreWithStats(context.Background(), req)
+		if (err != nil) != test.fail {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		mtx.Lock()
+		got := sent
+		mtx.Unlock()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%d. Unexpected samples sent; want %v, got %v", i, test.want, got)
+		}
+		if stats.DuplicateSamples != test.duplicates {
+			t.Fatalf("%d. Unexpected number of duplicates; want %d, got %d", i, test.duplicates, stats.DuplicateSamples)
+		}
+		if len(req.Timeseries) != len(test.series) {
+			t.Fatalf("%d. Deduplication modified the original request: %v", i, req)
+		}
+	}
+}
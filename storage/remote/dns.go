@@ -0,0 +1,125 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dnsCache dials hosts by the addresses they resolved to within the TTL,
+// saving the DNS lookup the dialer of the default transport makes for every
+// connection.
+type dnsCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mtx     sync.Mutex
+	entries map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	// The same dialer settings as the default transport.
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &dnsCache{
+		ttl:     ttl,
+		lookup:  net.DefaultResolver.LookupIPAddr,
+		dial:    dialer.DialContext,
+		entries: map[string]dnsCacheEntry{},
+	}
+}
+
+// option makes a transport dial through the cache.
+func (c *dnsCache) option(t *http.Transport) {
+	t.DialContext = c.DialContext
+}
+
+// DialContext dials the addresses the host of addr resolves to in turn,
+// until one of them accepts the connection.
+func (c *dnsCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return c.dial(ctx, network, addr)
+	}
+
+	addrs, err := c.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	err = fmt.Errorf("no %s address found for host %s", network, host)
+	for _, ip := range addrs {
+		if (network == "tcp4" && ip.IP.To4() == nil) || (network == "tcp6" && ip.IP.To4() != nil) {
+			continue
+		}
+		var conn net.Conn
+		conn, err = c.dial(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	// The addresses may have changed, look them up again next time.
+	c.mtx.Lock()
+	delete(c.entries, host)
+	c.mtx.Unlock()
+	return nil, err
+}
+
+// resolve returns the addresses of a host, looking them up if they are not
+// cached or have expired.
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	c.mtx.Lock()
+	entry, ok := c.entries[host]
+	c.mtx.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	c.mtx.Lock()
+	c.entries[host] = dnsCacheEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mtx.Unlock()
+	return addrs, nil
+}
+
+// dialFunc returns a function dialing like the transport does.
+func dialFunc(t *http.Transport) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		if t.DialContext != nil {
+			return t.DialContext(context.Background(), network, addr)
+		}
+		return net.Dial(network, addr)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+	_, port, err := net.SplitHostPort(serverURL.Host)
+	if err != nil {
+		panic(err)
+	}
+
+	var lookups []string
+	cache := newDNSCache(100 * time.Millisecond)
+	cache.lookup = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		lookups = append(lookups, host)
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+
+	dial := func() {
+		conn, err := cache.DialContext(context.Background(), "tcp", net.JoinHostPort("remote.test", port))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		conn.Close()
+	}
+
+	dial()
+	dial()
+	if len(lookups) != 1 {
+		t.Fatalf("Unexpected lookups within the TTL; want 1, got %v", lookups)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	dial()
+	if len(lookups) != 2 {
+		t.Fatalf("Unexpected lookups after the TTL; want 2, got %v", lookups)
+	}
+
+	// Addresses are dialed directly, without lookups.
+	conn, err := cache.DialContext(context.Background(), "tcp", serverURL.Host)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	conn.Close()
+	if len(lookups) != 2 {
+		t.Fatalf("Unexpected lookup for an address: %v", lookups)
+	}
+
+	// Failed dials drop the cached addresses.
+	server.Close()
+	if _, err := cache.DialContext(context.Background(), "tcp", net.JoinHostPort("remote.test", port)); err == nil {
+		t.Fatal("Expected error dialing a closed server")
+	}
+	conn, err = cache.DialContext(context.Background(), "tcp", net.JoinHostPort("remote.test", port))
+	if err == nil {
+		conn.Close()
+	}
+	if len(lookups) != 3 {
+		t.Fatalf("Unexpected lookups after a failed dial; want 3, got %v", lookups)
+	}
+}
@@ -0,0 +1,432 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/common/log"
+	"golang.org/x/net/context"
+)
+
+// ErrQueueFull is returned by DurableQueue.Enqueue if persisting the batch
+// would exceed the size cap of the queue.
+var ErrQueueFull = errors.New("durable queue is full")
+
+// DurableQueueConfig configures a DurableQueue.
+type DurableQueueConfig struct {
+	// Dir is the directory the queue keeps its segments in. It is created
+	// if it does not exist, and must not be shared with other queues.
+	Dir string
+	// MaxBytes, if positive, caps the size of all segments on disk.
+	MaxBytes int64
+	// SegmentSize is the size at which a new segment is started. Segments
+	// are deleted once all their batches were sent, so 0, the default,
+	// deletes every batch right after sending it, while larger segments
+	// trade disk space for fewer files.
+	SegmentSize int64
+	// On recoverable errors, backoff exponentially. Batches are retried
+	// until they are sent or the queue is stopped. Defaults to the backoff
+	// of the QueueManager.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Each record is a length and a CRC32 of the marshalled write request,
+// followed by the write request itself.
+const recordHeaderLen = 8
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// walRecord locates a batch not yet sent on disk.
+type walRecord struct {
+	segment int
+	offset  int64
+	length  int
+}
+
+// DurableQueue persists batches of time series to disk before sending them
+// with a WriteClient, so that batches enqueued but not yet sent survive
+// restarts. Batches are sent in order, at least once: a batch stays on disk
+// until it was sent or rejected with an unrecoverable error.
+type DurableQueue struct {
+	client WriteClient
+	cfg    DurableQueueConfig
+
+	mtx      sync.Mutex
+	pending  []walRecord
+	unsent   map[int]int   // Number of unsent records by segment.
+	sizes    map[int]int64 // Sizes of the segments on disk.
+	size     int64
+	head     *os.File
+	headSeq  int
+	started  bool
+	notify   chan struct{}
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDurableQueue opens the queue in cfg.Dir, replaying the batches not yet
+// sent by a previous queue in the same directory. Call Start to send them.
+func NewDurableQueue(client WriteClient, cfg DurableQueueConfig) (*DurableQueue, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("no directory configured for the durable queue")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0777); err != nil {
+		return nil, err
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultQueueManagerConfig.MinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = defaultQueueManagerConfig.MaxBackoff
+		if cfg.MaxBackoff < cfg.MinBackoff {
+			cfg.MaxBackoff = cfg.MinBackoff
+		}
+	}
+	q := &DurableQueue{
+		client: client,
+		cfg:    cfg,
+		unsent: map[int]int{},
+		sizes:  map[int]int64{},
+		notify: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// replay indexes the records of the segments on disk. A record cut short by
+// a crash ends its segment, which is truncated to the records before it.
+func (q *DurableQueue) replay() error {
+	files, err := ioutil.ReadDir(q.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	var segments []int
+	for _, fi := range files {
+		if seq, err := strconv.Atoi(fi.Name()); err == nil && !fi.IsDir() {
+			segments = append(segments, seq)
+		}
+	}
+	sort.Ints(segments)
+
+	for _, seq := range segments {
+		records, valid, err := readSegment(q.segmentPath(seq))
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			if err := os.Remove(q.segmentPath(seq)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Truncate(q.segmentPath(seq), valid); err != nil {
+			return err
+		}
+		for _, r := range records {
+			r.segment = seq
+			q.pending = append(q.pending, r)
+		}
+		q.unsent[seq] = len(records)
+		q.sizes[seq] = valid
+		q.size += valid
+	}
+	if len(segments) > 0 {
+		q.headSeq = segments[len(segments)-1]
+	}
+	if len(q.pending) > 0 {
+		log.Infof("Replaying %d batches from the durable queue in %s", len(q.pending), q.cfg.Dir)
+	}
+	return nil
+}
+
+// readSegment returns the intact records of a segment and the number of
+// bytes they take up.
+func readSegment(path string) ([]walRecord, int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		records []walRecord
+		offset  int64
+	)
+	for int64(len(data))-offset >= recordHeaderLen {
+		header := data[offset : offset+recordHeaderLen]
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		end := offset + recordHeaderLen + length
+		if end > int64(len(data)) {
+			break
+		}
+		if crc32.Checksum(data[offset+recordHeaderLen:end], castagnoli) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		records = append(records, walRecord{offset: offset, length: int(length)})
+		offset = end
+	}
+	return records, offset, nil
+}
+
+func (q *DurableQueue) segmentPath(seq int) string {
+	return filepath.Join(q.cfg.Dir, fmt.Sprintf("%08d", seq))
+}
+
+// Enqueue persists a batch of time series, to be sent after all batches
+// enqueued before it. Once it returns nil, the batch is on disk and is sent
+// even if the process restarts in between. It returns ErrQueueFull if the
+// batch does not fit within the size cap.
+func (q *DurableQueue) Enqueue(series []*TimeSeries) error {
+	data, err := proto.Marshal(&WriteRequest{Timeseries: series})
+	if err != nil {
+		return err
+	}
+	record := make([]byte, recordHeaderLen+len(data))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(data, castagnoli))
+	copy(record[recordHeaderLen:], data)
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.cfg.MaxBytes > 0 && q.size+int64(len(record)) > q.cfg.MaxBytes {
+		return ErrQueueFull
+	}
+	if q.head == nil || (q.sizes[q.headSeq] > 0 && q.sizes[q.headSeq]+int64(len(record)) > q.cfg.SegmentSize) {
+		if err := q.cut(); err != nil {
+			return err
+		}
+	}
+
+	offset := q.sizes[q.headSeq]
+	if _, err := q.head.Write(record); err != nil {
+		// Drop what was written of the record, so that it does not end
+		// the segment on replay.
+		q.head.Truncate(offset)
+		q.head.Seek(offset, io.SeekStart)
+		return err
+	}
+	if err := q.head.Sync(); err != nil {
+		return err
+	}
+
+	q.pending = append(q.pending, walRecord{segment: q.headSeq, offset: offset, length: len(data)})
+	q.unsent[q.headSeq]++
+	q.sizes[q.headSeq] += int64(len(record))
+	q.size += int64(len(record))
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// cut starts a new head segment. The previous one is deleted if all its
+// batches were sent already.
+func (q *DurableQueue) cut() error {
+	if q.head != nil {
+		if err := q.head.Close(); err != nil {
+			return err
+		}
+		q.head = nil
+		if err := q.truncate(q.headSeq); err != nil {
+			return err
+		}
+	}
+	seq := q.headSeq + 1
+	f, err := os.OpenFile(q.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	q.head, q.headSeq = f, seq
+	q.sizes[seq] = 0
+	return nil
+}
+
+// truncate deletes a segment unless it holds unsent batches or is the head
+// segment being written to. It must be called with mtx held.
+func (q *DurableQueue) truncate(seq int) error {
+	if q.unsent[seq] > 0 || (q.head != nil && seq == q.headSeq) {
+		return nil
+	}
+	if err := os.Remove(q.segmentPath(seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.size -= q.sizes[seq]
+	delete(q.sizes, seq)
+	delete(q.unsent, seq)
+	return nil
+}
+
+// Len returns the number of batches waiting to be sent.
+func (q *DurableQueue) Len() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return len(q.pending)
+}
+
+// Start starts sending the queued batches. Does not block.
+func (q *DurableQueue) Start() {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if !q.started {
+		q.started = true
+		go q.run()
+	}
+}
+
+// Stop stops sending batches, waiting for the batch being sent, if any.
+// Batches not sent yet stay on disk for the next queue opened in the same
+// directory.
+func (q *DurableQueue) Stop() {
+	q.stopOnce.Do(func() {
+		q.mtx.Lock()
+		started := q.started
+		q.started = true // Keep Start from sending again.
+		q.mtx.Unlock()
+
+		close(q.quit)
+		if started {
+			<-q.done
+		}
+
+		q.mtx.Lock()
+		defer q.mtx.Unlock()
+		if q.head != nil {
+			if err := q.head.Close(); err != nil {
+				log.Warnf("Error closing the durable queue segment: %s", err)
+			}
+			q.head = nil
+			if err := q.truncate(q.headSeq); err != nil {
+				log.Warnf("Error deleting sent segment of the durable queue: %s", err)
+			}
+		}
+	})
+}
+
+func (q *DurableQueue) run() {
+	defer close(q.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-q.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		q.mtx.Lock()
+		if len(q.pending) == 0 {
+			q.mtx.Unlock()
+			select {
+			case <-q.notify:
+				continue
+			case <-q.quit:
+				return
+			}
+		}
+		r := q.pending[0]
+		q.mtx.Unlock()
+
+		if !q.send(ctx, r) {
+			return
+		}
+
+		q.mtx.Lock()
+		q.pending = q.pending[1:]
+		q.unsent[r.segment]--
+		if err := q.truncate(r.segment); err != nil {
+			log.Warnf("Error deleting sent segment of the durable queue: %s", err)
+		}
+		q.mtx.Unlock()
+	}
+}
+
+// send sends a record, retrying recoverable errors until it succeeds or the
+// queue is stopped. It returns false if the queue was stopped before the
+// record was sent.
+func (q *DurableQueue) send(ctx context.Context, r walRecord) bool {
+	req, err := q.read(r)
+	if err != nil {
+		// The record was intact when written, so this is not going to get
+		// any better by retrying.
+		log.Errorf("Error reading batch from the durable queue, dropping it: %s", err)
+		return true
+	}
+
+	backoff := q.cfg.MinBackoff
+	for {
+		err := q.client.Store(ctx, req)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Warnf("Error sending batch of %d series from the durable queue: %s", len(req.Timeseries), err)
+		if _, ok := err.(recoverableError); !ok {
+			return true
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false
+		}
+		backoff = backoff * 2
+		if backoff > q.cfg.MaxBackoff {
+			backoff = q.cfg.MaxBackoff
+		}
+	}
+}
+
+// read loads and decodes a record from its segment.
+func (q *DurableQueue) read(r walRecord) (*WriteRequest, error) {
+	f, err := os.Open(q.segmentPath(r.segment))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, r.length)
+	if _, err := f.ReadAt(data, r.offset+recordHeaderLen); err != nil {
+		return nil, err
+	}
+	var req WriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+)
+
+// recordingWriteClient records the write requests it is sent, failing with
+// err if it is set.
+type recordingWriteClient struct {
+	mtx  sync.Mutex
+	reqs []*WriteRequest
+	err  error
+	sent chan struct{}
+}
+
+func newRecordingWriteClient(err error) *recordingWriteClient {
+	return &recordingWriteClient{err: err, sent: make(chan struct{}, 100)}
+}
+
+func (c *recordingWriteClient) Store(_ context.Context, req *WriteRequest) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.reqs = append(c.reqs, req)
+	c.sent <- struct{}{}
+	return nil
+}
+
+func (c *recordingWriteClient) waitFor(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.sent:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for write request %d", i+1)
+		}
+	}
+}
+
+// timeoutFirstRequest returns a test handler timing out the first write
+// request, until the returned function is called, and passing on the others.
+func timeoutFirstRequest() (func(http.ResponseWriter, *http.Request) bool, func()) {
+	var requests int32
+	done := make(chan struct{})
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			return true
+		}
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+		return false
+	}, func() { close(done) }
+}
+
+func testQueueBatch(name string) []*TimeSeries {
+	return []*TimeSeries{{
+		Labels:  []*LabelPair{{Name: "__name__", Value: name}},
+		Samples: []*Sample{{Value: 1, TimestampMs: 1000}},
+	}}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range files {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+func TestDurableQueueRestart(t *testing.T) {
+	for _, segmentSize := range []int64{0, 1 << 20} {
+		dir, err := ioutil.TempDir("", "durable_queue")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// The remote end is down, so nothing gets sent before the restart.
+		down := newRecordingWriteClient(recoverableError{errors.New("connection refused")})
+		q, err := NewDurableQueue(down, DurableQueueConfig{
+			Dir:         dir,
+			SegmentSize: segmentSize,
+			MinBackoff:  time.Millisecond,
+			MaxBackoff:  time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.Start()
+		for _, name := range []string{"a", "b", "c"} {
+			if err := q.Enqueue(testQueueBatch(name)); err != nil {
+				t.Fatalf("%d: unexpected error: %v", segmentSize, err)
+			}
+		}
+		q.Stop()
+
+		up := newRecordingWriteClient(nil)
+		q, err = NewDurableQueue(up, DurableQueueConfig{Dir: dir, SegmentSize: segmentSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Len() != 3 {
+			t.Fatalf("%d: unexpected number of replayed batches; want 3, got %d", segmentSize, q.Len())
+		}
+		q.Start()
+		up.waitFor(t, 3)
+		if err := q.Enqueue(testQueueBatch("d")); err != nil {
+			t.Fatalf("%d: unexpected error: %v", segmentSize, err)
+		}
+		up.waitFor(t, 1)
+		q.Stop()
+
+		var want []*WriteRequest
+		for _, name := range []string{"a", "b", "c", "d"} {
+			want = append(want, &WriteRequest{Timeseries: testQueueBatch(name)})
+		}
+		if !reflect.DeepEqual(up.reqs, want) {
+			t.Fatalf("%d: unexpected write requests; want %v, got %v", segmentSize, want, up.reqs)
+		}
+
+		// Segments are deleted once everything was sent.
+		if files := segmentFiles(t, dir); len(files) != 0 {
+			t.Fatalf("%d: sent segments were not deleted: %v", segmentSize, files)
+		}
+		q, err = NewDurableQueue(up, DurableQueueConfig{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Len() != 0 {
+			t.Fatalf("%d: sent batches were replayed: %d", segmentSize, q.Len())
+		}
+	}
+}
+
+func TestDurableQueueTornRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "durable_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := NewDurableQueue(newRecordingWriteClient(nil), DurableQueueConfig{Dir: dir, SegmentSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(testQueueBatch("a")); err != nil {
+		t.Fatal(err)
+	}
+	q.Stop()
+
+	// Simulate a crash in the middle of writing a record.
+	files := segmentFiles(t, dir)
+	f, err := os.OpenFile(filepath.Join(dir, files[0]), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 1, 0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	up := newRecordingWriteClient(nil)
+	q, err = NewDurableQueue(up, DurableQueueConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("Unexpected number of replayed batches; want 1, got %d", q.Len())
+	}
+	q.Start()
+	up.waitFor(t, 1)
+	q.Stop()
+	if want := []*WriteRequest{{Timeseries: testQueueBatch("a")}}; !reflect.DeepEqual(up.reqs, want) {
+		t.Fatalf("Unexpected write requests; want %v, got %v", want, up.reqs)
+	}
+}
+
+func TestDurableQueueFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "durable_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := NewDurableQueue(newRecordingWriteClient(nil), DurableQueueConfig{Dir: dir, MaxBytes: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop()
+
+	if err := q.Enqueue(testQueueBatch("a")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := q.Enqueue(testQueueBatch("b")); err != ErrQueueFull {
+		t.Fatalf("Unexpected error; want %v, got %v", ErrQueueFull, err)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("Unexpected number of queued batches; want 1, got %d", q.Len())
+	}
+}
+
+func TestDurableQueueTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "durable_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	handle, unblock := timeoutFirstRequest()
+	c, reqs, stop := newWriteTestClient(t, &ClientConfig{Timeout: model.Duration(50 * time.Millisecond)}, handle)
+	defer stop()
+	defer unblock()
+	q, err := NewDurableQueue(c, DurableQueueConfig{
+		Dir:        dir,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Stop()
+	if err := q.Enqueue(testQueueBatch("a")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The batch is sent again, rather than dropped, after timing out.
+	select {
+	case req := <-reqs:
+		if want := (&WriteRequest{Timeseries: testQueueBatch("a")}); !reflect.DeepEqual(req, want) {
+			t.Fatalf("Unexpected write request; want %v, got %v", want, req)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the batch to be sent again")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+const defaultFailoverProbeInterval = 30 * time.Second
+
+// failover tracks which of a list of write endpoints, primary first, is
+// written to. It sticks to the last one that worked, going back to the
+// primary once per probe interval to check whether it recovered.
+type failover struct {
+	urls          []*config.URL
+	probeInterval time.Duration
+
+	mtx       sync.Mutex
+	active    int
+	lastProbe time.Time
+}
+
+func newFailover(urls []*config.URL, probeInterval time.Duration) *failover {
+	if probeInterval == 0 {
+		probeInterval = defaultFailoverProbeInterval
+	}
+	return &failover{urls: urls, probeInterval: probeInterval}
+}
+
+// order returns the indexes of the endpoints in the order they are to be
+// tried, starting with the active one, or with the primary if it is due
+// for a probe.
+func (f *failover) order() []int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	first := f.active
+	if first != 0 && time.Since(f.lastProbe) >= f.probeInterval {
+		first = 0
+		f.lastProbe = time.Now()
+	}
+	order := make([]int, 0, len(f.urls))
+	order = append(order, first)
+	for i := range f.urls {
+		if j := (f.active + i) % len(f.urls); j != first {
+			order = append(order, j)
+		}
+	}
+	return order
+}
+
+// use makes the endpoint with the given index the active one.
+func (f *failover) use(i int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if i != 0 && f.active == 0 {
+		// Wait for a full probe interval before going back.
+		f.lastProbe = time.Now()
+	}
+	f.active = i
+}
+
+// writeFailover sends a write request to the write endpoint, or, if several
+// are configured, to each of them in turn until one does not fail with a
+// recoverable error.
+func (c *Client) writeFailover(ctx context.Context, req *WriteRequest, stats *WriteStats) error {
+	if c.failover == nil {
+		return c.write(ctx, c.url, req, stats)
+	}
+
+	var (
+		err   error
+		order = c.failover.order()
+	)
+	for n, i := range order {
+		u := c.failover.urls[i]
+		if err = c.write(ctx, u, req, stats); err == nil {
+			c.failover.use(i)
+			return nil
+		}
+		if _, ok := err.(recoverableError); !ok {
+			return err
+		}
+		if n < len(order)-1 {
+			c.logger.With("url", u.String()).Warnf("Failing over after error sending write request: %s", err)
+		}
+	}
+	return err
+}
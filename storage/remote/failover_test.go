@@ -0,0 +1,135 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// failoverServer responds to write requests with the status it is set to,
+// counting them.
+type failoverServer struct {
+	*httptest.Server
+	status int32
+	hits   int32
+}
+
+func newFailoverServer(status int) *failoverServer {
+	s := &failoverServer{status: int32(status)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+	}))
+	return s
+}
+
+func (s *failoverServer) url() *config.URL {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		panic(err)
+	}
+	return &config.URL{URL: u}
+}
+
+func TestClientFailover(t *testing.T) {
+	primary := newFailoverServer(http.StatusServiceUnavailable)
+	defer primary.Close()
+	secondary := newFailoverServer(http.StatusNoContent)
+	defer secondary.Close()
+
+	c, err := NewClient(0, &ClientConfig{
+		URLs:                  []*config.URL{primary.url(), secondary.url()},
+		Timeout:               model.Duration(time.Second),
+		FailoverProbeInterval: model.Duration(100 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := func(wantPrimary, wantSecondary int32) {
+		if got := atomic.LoadInt32(&primary.hits); got != wantPrimary {
+			t.Fatalf("Unexpected primary requests; want %d, got %d", wantPrimary, got)
+		}
+		if got := atomic.LoadInt32(&secondary.hits); got != wantSecondary {
+			t.Fatalf("Unexpected secondary requests; want %d, got %d", wantSecondary, got)
+		}
+	}
+
+	if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hits(1, 1)
+
+	// The secondary sticks until the primary is probed again.
+	if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hits(1, 2)
+
+	atomic.StoreInt32(&primary.status, http.StatusNoContent)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	hits(3, 2)
+
+	// Non-recoverable errors do not fail over.
+	atomic.StoreInt32(&primary.status, http.StatusBadRequest)
+	if err := c.Store(context.Background(), testWriteRequest()); err == nil {
+		t.Fatal("Expected error from the primary")
+	}
+	hits(4, 2)
+
+	// All endpoints failing returns the last error.
+	atomic.StoreInt32(&primary.status, http.StatusServiceUnavailable)
+	atomic.StoreInt32(&secondary.status, http.StatusServiceUnavailable)
+	err = c.Store(context.Background(), testWriteRequest())
+	if _, ok := err.(recoverableError); !ok {
+		t.Fatalf("Expected recoverable error, got %v", err)
+	}
+	hits(5, 3)
+}
+
+func TestNewClientFailoverValidation(t *testing.T) {
+	u := &config.URL{URL: &url.URL{Scheme: "http", Host: "localhost:9201"}}
+	for i, conf := range []ClientConfig{
+		{URL: u, URLs: []*config.URL{u}},
+		{URLs: []*config.URL{u, nil}},
+		{URLs: []*config.URL{u}, FailoverProbeInterval: model.Duration(-time.Second)},
+	} {
+		if _, err := NewClient(0, &conf); err == nil {
+			t.Fatalf("%d. Expected error for invalid configuration", i)
+		}
+	}
+
+	c, err := NewClient(0, &ClientConfig{URLs: []*config.URL{u}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.url != u || c.Name() != "0:"+u.String() {
+		t.Fatalf("Unexpected write URL of a single URL: %s", c.Name())
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// TenantHeader selects the tenant of requests to multi-tenant servers.
+const TenantHeader = "X-Scope-OrgID"
+
+type tenantKey struct{}
+
+// WithTenant returns a context making read requests made with it select the
+// given tenant, taking precedence over a tenant header configured for the
+// client.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// tenantFromContext returns the tenant set with WithTenant, if any.
+func tenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok
+}
+
+// reservedHeaders are set by the client itself and cannot be overridden by
+// configured headers.
+var reservedHeaders = map[string]struct{}{
+	"Authorization":                     {},
+	"Content-Encoding":                  {},
+	"Content-Length":                    {},
+	"Content-Type":                      {},
+	"User-Agent":                        {},
+	"X-Prometheus-Remote-Read-Version":  {},
+	"X-Prometheus-Remote-Write-Version": {},
+}
+
+// validateHeaders returns an error if any of the given headers is reserved.
+func validateHeaders(headers map[string]string) error {
+	for name := range headers {
+		if _, ok := reservedHeaders[http.CanonicalHeaderKey(name)]; ok {
+			return fmt.Errorf("header %q is reserved and cannot be configured", name)
+		}
+	}
+	return nil
+}
+
+// headersRoundTripper sets a fixed set of headers on every request, unless
+// the request has them set already, before handing it to the next
+// http.RoundTripper.
+type headersRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func newHeadersRoundTripper(headers map[string]string, next http.RoundTripper) http.RoundTripper {
+	return &headersRoundTripper{headers: headers, next: next}
+}
+
+func (rt *headersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Modify a copy, as a RoundTripper must not modify the original request.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+len(rt.headers))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	for k, v := range rt.headers {
+		if r.Header.Get(k) == "" {
+			r.Header.Set(k, v)
+		}
+	}
+	return rt.next.RoundTrip(r)
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+)
+
+// Valid range of native histogram schemas.
+const (
+	minHistogramSchema = -4
+	maxHistogramSchema = 8
+)
+
+// validateHistograms checks the native histograms of a write request for
+// consistency, so that a single malformed histogram can be pinpointed instead
+// of having the whole request rejected by the server.
+func validateHistograms(req *WriteRequest) error {
+	for _, ts := range req.Timeseries {
+		for _, h := range ts.Histograms {
+			if err := validateHistogram(h); err != nil {
+				return fmt.Errorf("invalid histogram at %d in series %s: %s", h.TimestampMs, labelPairsToMetric(ts.Labels), err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHistogram(h *Histogram) error {
+	if h.Schema < minHistogramSchema || h.Schema > maxHistogramSchema {
+		return fmt.Errorf("schema %d out of range [%d, %d]", h.Schema, minHistogramSchema, maxHistogramSchema)
+	}
+	if err := validateBuckets("negative", h.NegativeSpans, len(h.NegativeDeltas), len(h.NegativeCounts)); err != nil {
+		return err
+	}
+	return validateBuckets("positive", h.PositiveSpans, len(h.PositiveDeltas), len(h.PositiveCounts))
+}
+
+// validateBuckets checks that the spans of one side of a histogram cover
+// exactly as many buckets as there are deltas or counts. Only one of the two
+// may be set.
+func validateBuckets(side string, spans []*BucketSpan, deltas, counts int) error {
+	if deltas > 0 && counts > 0 {
+		return fmt.Errorf("both %s deltas and counts set", side)
+	}
+	var buckets int
+	for i, s := range spans {
+		if i > 0 && s.Offset < 0 {
+			return fmt.Errorf("%s span %d has negative offset %d", side, i, s.Offset)
+		}
+		buckets += int(s.Length)
+	}
+	if n := deltas + counts; n != buckets {
+		return fmt.Errorf("%s spans cover %d buckets, but %d are given", side, buckets, n)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHistograms(t *testing.T) {
+	tests := []struct {
+		histogram *Histogram
+		err       string
+	}{
+		{
+			histogram: &Histogram{
+				CountInt:       6,
+				Schema:         3,
+				PositiveSpans:  []*BucketSpan{{Offset: -2, Length: 2}, {Offset: 1, Length: 1}},
+				PositiveDeltas: []int64{1, 1, 1},
+				NegativeSpans:  []*BucketSpan{{Offset: 0, Length: 1}},
+				NegativeDeltas: []int64{1},
+			},
+		},
+		{
+			histogram: &Histogram{
+				CountFloat:     3.5,
+				Schema:         -4,
+				PositiveSpans:  []*BucketSpan{{Offset: 0, Length: 2}},
+				PositiveCounts: []float64{1.5, 2},
+			},
+		},
+		{
+			histogram: &Histogram{Schema: 9},
+			err:       "schema 9 out of range",
+		},
+		{
+			histogram: &Histogram{
+				PositiveSpans:  []*BucketSpan{{Offset: 0, Length: 3}},
+				PositiveDeltas: []int64{1, 1},
+			},
+			err: "positive spans cover 3 buckets, but 2 are given",
+		},
+		{
+			histogram: &Histogram{
+				NegativeSpans:  []*BucketSpan{{Offset: 0, Length: 1}},
+				NegativeDeltas: []int64{1},
+				NegativeCounts: []float64{1},
+			},
+			err: "both negative deltas and counts set",
+		},
+	}
+
+	for i, test := range tests {
+		req := &WriteRequest{
+			Timeseries: []*TimeSeries{
+				{
+					Labels:     []*LabelPair{{Name: "__name__", Value: "test_histogram"}},
+					Histograms: []*Histogram{test.histogram},
+				},
+			},
+		}
+		err := validateHistograms(req)
+		if test.err == "" {
+			if err != nil {
+				t.Fatalf("%d. Unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) || !strings.Contains(err.Error(), "test_histogram") {
+			t.Fatalf("%d. Unexpected error; want error containing %q and the series, got %v", i, test.err, err)
+		}
+	}
+}
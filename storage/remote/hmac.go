@@ -0,0 +1,113 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the header holding the hex-encoded HMAC-SHA256 of the
+// request body, computed with the shared secret of HMACSecretFile.
+const SignatureHeader = "X-Signature"
+
+// hmacRoundTripper signs the body of every request with a shared secret
+// before handing it to the next http.RoundTripper. Signing happens per round
+// trip, so retried requests are signed afresh, with the secret re-read from
+// its file whenever the file changed.
+type hmacRoundTripper struct {
+	secretFile string
+	next       http.RoundTripper
+
+	mtx     sync.Mutex
+	secret  []byte
+	modTime time.Time
+	size    int64
+}
+
+func newHMACRoundTripper(secretFile string, next http.RoundTripper) (http.RoundTripper, error) {
+	rt := &hmacRoundTripper{secretFile: secretFile, next: next}
+	if _, err := rt.key(); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// key returns the current secret, re-reading the file if it changed.
+// Surrounding whitespace, like a trailing newline, is not part of it.
+func (rt *hmacRoundTripper) key() ([]byte, error) {
+	fi, err := os.Stat(rt.secretFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read HMAC secret file %s: %s", rt.secretFile, err)
+	}
+
+	rt.mtx.Lock()
+	defer rt.mtx.Unlock()
+
+	if rt.secret != nil && rt.modTime.Equal(fi.ModTime()) && rt.size == fi.Size() {
+		return rt.secret, nil
+	}
+	b, err := ioutil.ReadFile(rt.secretFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read HMAC secret file %s: %s", rt.secretFile, err)
+	}
+	secret := []byte(strings.TrimSpace(string(b)))
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("HMAC secret file %s is empty", rt.secretFile)
+	}
+	rt.secret = secret
+	rt.modTime = fi.ModTime()
+	rt.size = fi.Size()
+	return rt.secret, nil
+}
+
+func (rt *hmacRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := rt.key()
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Sign a copy, as a RoundTripper must not modify the original request.
+	signed := new(http.Request)
+	*signed = *req
+	signed.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		signed.Header[k] = v
+	}
+	if req.Body != nil {
+		signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	signed.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return rt.next.RoundTrip(signed)
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestClientHMAC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote_hmac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretFile, []byte("first secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mtx      sync.Mutex
+		requests int
+		secret   = "first secret"
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			requests++
+
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			if want, got := hex.EncodeToString(mac.Sum(nil)), r.Header.Get(SignatureHeader); got != want {
+				http.Error(w, "signature mismatch: want "+want+", got "+got, http.StatusUnauthorized)
+				return
+			}
+			// Fail the first attempt, to check that the retry is signed too.
+			if requests == 1 {
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:              &config.URL{URL: serverURL},
+		Timeout:          model.Duration(time.Second),
+		RetryMaxAttempts: 2,
+		HMACSecretFile:   secretFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := toWriteRequest(model.Samples{{Metric: model.Metric{model.MetricNameLabel: "a"}, Value: 1}})
+	if err := c.Store(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("Unexpected number of requests; want 2, got %d", requests)
+	}
+
+	// A rotated secret is picked up.
+	if err := ioutil.WriteFile(secretFile, []byte("rotated secret, longer\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtx.Lock()
+	secret = "rotated secret, longer"
+	mtx.Unlock()
+	if err := c.Store(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error after rotating the secret: %v", err)
+	}
+
+	if _, err := NewClient(0, &ClientConfig{
+		URL:            &config.URL{URL: serverURL},
+		HMACSecretFile: filepath.Join(dir, "missing"),
+	}); err == nil {
+		t.Fatal("Expected error for a missing secret file")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+)
+
+// validateLabels checks the labels of all series of a write request, so that
+// a single malformed series can be pinpointed instead of having the whole
+// request rejected by the server. With utf8Names, metric and label names may
+// be any valid UTF-8, otherwise they are restricted to the legacy character
+// set.
+func validateLabels(req *WriteRequest, utf8Names bool) error {
+	for _, ts := range req.Timeseries {
+		if err := validateSeriesLabels(ts.Labels, utf8Names); err != nil {
+			return fmt.Errorf("invalid labels in series %s: %s", labelPairsToMetric(ts.Labels), err)
+		}
+	}
+	return nil
+}
+
+func validateSeriesLabels(labels []*LabelPair, utf8Names bool) error {
+	seen := make(map[string]struct{}, len(labels))
+	for _, l := range labels {
+		switch {
+		case l.Name == "":
+			return fmt.Errorf("empty label name")
+		case utf8Names && !utf8.ValidString(l.Name):
+			return fmt.Errorf("label name %q is not valid UTF-8", l.Name)
+		case !utf8Names && l.Name == model.MetricNameLabel && !model.IsValidMetricName(model.LabelValue(l.Value)):
+			return fmt.Errorf("invalid metric name %q", l.Value)
+		case !utf8Names && !model.LabelName(l.Name).IsValid():
+			return fmt.Errorf("invalid label name %q", l.Name)
+		}
+		if _, ok := seen[l.Name]; ok {
+			return fmt.Errorf("duplicate label name %q", l.Name)
+		}
+		seen[l.Name] = struct{}{}
+
+		switch {
+		case l.Value == "":
+			return fmt.Errorf("empty value of label %q", l.Name)
+		case !model.LabelValue(l.Value).IsValid():
+			return fmt.Errorf("value of label %q is not valid UTF-8", l.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		labels    []*LabelPair
+		utf8Names bool
+		err       string
+	}{
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "http_requests:rate5m"}, {Name: "job", Value: "api"}},
+		},
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "", Value: "api"}},
+			err:    "empty label name",
+		},
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "job", Value: ""}},
+			err:    `empty value of label "job"`,
+		},
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "job", Value: "\xff"}},
+			err:    `value of label "job" is not valid UTF-8`,
+		},
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}, {Name: "job", Value: "b"}},
+			err:    `duplicate label name "job"`,
+		},
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "http.requests"}},
+			err:    `invalid metric name "http.requests"`,
+		},
+		{
+			labels: []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "service.name", Value: "api"}},
+			err:    `invalid label name "service.name"`,
+		},
+		{
+			labels:    []*LabelPair{{Name: "__name__", Value: "http.requests"}, {Name: "service.name", Value: "api"}},
+			utf8Names: true,
+		},
+		{
+			labels:    []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "\xff", Value: "api"}},
+			utf8Names: true,
+			err:       "is not valid UTF-8",
+		},
+		{
+			labels:    []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "", Value: "api"}},
+			utf8Names: true,
+			err:       "empty label name",
+		},
+	}
+
+	for i, test := range tests {
+		req := &WriteRequest{
+			Timeseries: []*TimeSeries{{
+				Labels:  []*LabelPair{{Name: "__name__", Value: "valid"}},
+				Samples: []*Sample{{Value: 1, TimestampMs: 1}},
+			}, {
+				Labels:  test.labels,
+				Samples: []*Sample{{Value: 1, TimestampMs: 1}},
+			}},
+		}
+		err := validateLabels(req, test.utf8Names)
+		if test.err == "" {
+			if err != nil {
+				t.Fatalf("%d. Unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) || !strings.Contains(err.Error(), labelPairsToMetric(test.labels).String()) {
+			t.Fatalf("%d. Unexpected error; want error containing %q and the series, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestClientValidateLabels(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:            &config.URL{URL: serverURL},
+		Timeout:        model.Duration(time.Second),
+		ValidateLabels: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := &TimeSeries{
+		Labels:  []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "job", Value: "api"}},
+		Samples: []*Sample{{Value: 1, TimestampMs: 1}},
+	}
+	if err := c.Store(context.Background(), &WriteRequest{Timeseries: []*TimeSeries{valid}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	invalid := &TimeSeries{
+		Labels:  []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "", Value: "api"}},
+		Samples: []*Sample{{Value: 1, TimestampMs: 1}},
+	}
+	err = c.Store(context.Background(), &WriteRequest{Timeseries: []*TimeSeries{valid, invalid}})
+	if err == nil || !strings.Contains(err.Error(), "empty label name") {
+		t.Fatalf("Expected empty label name error, got %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("Unexpected number of requests; want 1, got %d", got)
+	}
+}
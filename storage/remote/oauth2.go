@@ -0,0 +1,131 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OAuth2Config configures authorization with bearer tokens obtained through
+// the OAuth 2.0 client credentials grant.
+type OAuth2Config struct {
+	ClientID string
+	// ClientSecretFile is read on every token request, so that the secret
+	// can be rotated without a restart.
+	ClientSecretFile string
+	TokenURL         string
+	Scopes           []string
+	// EndpointParams are additional parameters of token requests.
+	EndpointParams map[string]string
+}
+
+// newOAuth2RoundTripper returns a round tripper authorizing requests with a
+// token from the configured token endpoint. The token is cached and shared by
+// all requests, and fetched anew shortly before it expires. Tokens are
+// requested through next, which must not add authorization of its own.
+func newOAuth2RoundTripper(cfg *OAuth2Config, next http.RoundTripper) (http.RoundTripper, error) {
+	if cfg.TokenURL == "" {
+		return nil, fmt.Errorf("no token URL configured for oauth2")
+	}
+	if _, err := url.Parse(cfg.TokenURL); err != nil {
+		return nil, fmt.Errorf("invalid oauth2 token URL %q: %s", cfg.TokenURL, err)
+	}
+
+	src := &clientCredentialsSource{
+		cfg:    cfg,
+		client: &http.Client{Transport: next},
+	}
+	return &oauth2.Transport{
+		Source: oauth2.ReuseTokenSource(nil, src),
+		Base:   next,
+	}, nil
+}
+
+// clientCredentialsSource fetches tokens with the client credentials grant
+// of RFC 6749, section 4.4.
+type clientCredentialsSource struct {
+	cfg    *OAuth2Config
+	client *http.Client
+}
+
+func (s *clientCredentialsSource) Token() (*oauth2.Token, error) {
+	var secret string
+	if s.cfg.ClientSecretFile != "" {
+		b, err := ioutil.ReadFile(s.cfg.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read oauth2 client secret file %s: %s", s.cfg.ClientSecretFile, err)
+		}
+		secret = strings.TrimSpace(string(b))
+	}
+
+	v := url.Values{}
+	for k, p := range s.cfg.EndpointParams {
+		v.Set(k, p)
+	}
+	v.Set("grant_type", "client_credentials")
+	if len(s.cfg.Scopes) > 0 {
+		v.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+
+	req, err := http.NewRequest("POST", s.cfg.TokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(secret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oauth2 token: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oauth2 token: %s", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("error fetching oauth2 token: server returned HTTP status %s: %s", resp.Status, body)
+	}
+
+	var tj struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tj); err != nil {
+		return nil, fmt.Errorf("error decoding oauth2 token: %s", err)
+	}
+	if tj.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2 token response contains no access token")
+	}
+
+	token := &oauth2.Token{
+		AccessToken: tj.AccessToken,
+		TokenType:   tj.TokenType,
+	}
+	if tj.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tj.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestClientOAuth2(t *testing.T) {
+	secretFile, err := ioutil.TempFile("", "oauth2_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secretFile.Name())
+	if _, err := secretFile.WriteString("secret\n"); err != nil {
+		t.Fatal(err)
+	}
+	secretFile.Close()
+
+	for _, test := range []struct {
+		expiresIn     int
+		tokenRequests int32
+	}{
+		// Long-lived tokens are reused across requests.
+		{expiresIn: 3600, tokenRequests: 1},
+		// Tokens about to expire are refreshed.
+		{expiresIn: 1, tokenRequests: 3},
+	} {
+		var tokenRequests int32
+		tokenServer := httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id, secret, ok := r.BasicAuth()
+				if !ok || id != "client" || secret != "secret" {
+					http.Error(w, "invalid client credentials", http.StatusUnauthorized)
+					return
+				}
+				if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" || r.FormValue("audience") != "remote" {
+					http.Error(w, fmt.Sprintf("unexpected token request %v", r.Form), http.StatusBadRequest)
+					return
+				}
+				n := atomic.AddInt32(&tokenRequests, 1)
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "bearer", "expires_in": %d}`, n, test.expiresIn)
+			}),
+		)
+
+		var authorized int32
+		server := httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				want := fmt.Sprintf("Bearer token%d", atomic.LoadInt32(&tokenRequests))
+				if got := r.Header.Get("Authorization"); got != want {
+					http.Error(w, fmt.Sprintf("unexpected authorization %q, want %q", got, want), http.StatusUnauthorized)
+					return
+				}
+				atomic.AddInt32(&authorized, 1)
+			}),
+		)
+
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			panic(err)
+		}
+
+		c, err := NewClient(0, &ClientConfig{
+			URL:     &config.URL{URL: serverURL},
+			Timeout: model.Duration(time.Second),
+			OAuth2: &OAuth2Config{
+				ClientID:         "client",
+				ClientSecretFile: secretFile.Name(),
+				TokenURL:         tokenServer.URL,
+				Scopes:           []string{"read", "write"},
+				EndpointParams:   map[string]string{"audience": "remote"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+				t.Fatalf("expires_in=%d: unexpected error: %v", test.expiresIn, err)
+			}
+		}
+		if got := atomic.LoadInt32(&authorized); got != 3 {
+			t.Fatalf("expires_in=%d: unexpected number of authorized requests; want 3, got %d", test.expiresIn, got)
+		}
+		if got := atomic.LoadInt32(&tokenRequests); got != test.tokenRequests {
+			t.Fatalf("expires_in=%d: unexpected number of token requests; want %d, got %d", test.expiresIn, test.tokenRequests, got)
+		}
+
+		server.Close()
+		tokenServer.Close()
+	}
+}
+
+func TestClientOAuth2ConflictingAuth(t *testing.T) {
+	_, err := NewClient(0, &ClientConfig{
+		HTTPClientConfig: config.HTTPClientConfig{BearerToken: "token"},
+		OAuth2:           &OAuth2Config{TokenURL: "http://localhost/token"},
+	})
+	if err == nil {
+		t.Fatal("Expected error for oauth2 combined with bearer token")
+	}
+}
@@ -14,10 +14,13 @@
 package remote
 
 import (
+	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/net/context"
 	"golang.org/x/time/rate"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -102,7 +105,11 @@ var (
 		},
 		[]string{queue},
 	)
-	numShards = prometheus.NewGaugeVec(
+	numShards = newNumShards()
+)
+
+func newNumShards() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subsystem,
@@ -111,7 +118,7 @@ var (
 		},
 		[]string{queue},
 	)
-)
+}
 
 func init() {
 	prometheus.MustRegister(succeededSamplesTotal)
@@ -123,11 +130,74 @@ func init() {
 	prometheus.MustRegister(numShards)
 }
 
+// queueManagerMetrics are the metrics of the sharding of queue managers and
+// of their backlog.
+type queueManagerMetrics struct {
+	shards         *prometheus.GaugeVec
+	desiredShards  *prometheus.GaugeVec
+	maxShards      *prometheus.GaugeVec
+	pendingSamples *prometheus.GaugeVec
+	enqueueRetries *prometheus.CounterVec
+}
+
+// newQueueManagerMetrics returns the queue manager metrics, registered with
+// r unless it is nil. Without r, the number of shards is reported by the
+// globally registered metric, as it is for the other queue metrics.
+func newQueueManagerMetrics(r prometheus.Registerer) *queueManagerMetrics {
+	m := &queueManagerMetrics{
+		shards: numShards,
+		desiredShards: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "shards_desired",
+			Help:      "The number of shards the remote storage queue asked for, given the rate of incoming and outgoing samples.",
+		},
+			[]string{queue},
+		),
+		maxShards: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "shards_max",
+			Help:      "The maximum number of shards the remote storage queue may use.",
+		},
+			[]string{queue},
+		),
+		pendingSamples: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "pending_samples",
+			Help:      "The number of samples appended to the remote storage queue and not sent yet, including those being sent.",
+		},
+			[]string{queue},
+		),
+		enqueueRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "enqueue_retries_total",
+			Help:      "Total number of times a batch of queued samples was sent again after a recoverable error.",
+		},
+			[]string{queue},
+		),
+	}
+
+	if r != nil {
+		m.shards = register(r, newNumShards()).(*prometheus.GaugeVec)
+		m.desiredShards = register(r, m.desiredShards).(*prometheus.GaugeVec)
+		m.maxShards = register(r, m.maxShards).(*prometheus.GaugeVec)
+		m.pendingSamples = register(r, m.pendingSamples).(*prometheus.GaugeVec)
+		m.enqueueRetries = register(r, m.enqueueRetries).(*prometheus.CounterVec)
+	}
+	return m
+}
+
 // QueueManagerConfig is the configuration for the queue used to write to remote
 // storage.
 type QueueManagerConfig struct {
 	// Number of samples to buffer per shard before we start dropping them.
 	QueueCapacity int
+	// Min number of shards, i.e. amount of concurrency. Capped at
+	// MaxShards.
+	MinShards int
 	// Max number of shards, i.e. amount of concurrency.
 	MaxShards int
 	// Maximum number of samples per send.
@@ -139,12 +209,17 @@ type QueueManagerConfig struct {
 	// On recoverable errors, backoff exponentially.
 	MinBackoff time.Duration
 	MaxBackoff time.Duration
+
+	// Registerer, if set, is used to register the sharding and backlog
+	// metrics, which are labelled with the queue name.
+	Registerer prometheus.Registerer
 }
 
 // defaultQueueManagerConfig is the default remote queue configuration.
 var defaultQueueManagerConfig = QueueManagerConfig{
 	// With a maximum of 1000 shards, assuming an average of 100ms remote write
 	// time and 100 samples per batch, we will be able to push 1M samples/s.
+	MinShards:         1,
 	MaxShards:         1000,
 	MaxSamplesPerSend: 100,
 
@@ -162,8 +237,8 @@ var defaultQueueManagerConfig = QueueManagerConfig{
 // StorageClient defines an interface for sending a batch of samples to an
 // external timeseries database.
 type StorageClient interface {
-	// Store stores the given samples in the remote storage.
-	Store(model.Samples) error
+	// Store stores the given write request in the remote storage.
+	Store(context.Context, *WriteRequest) error
 	// Name identifies the remote storage implementation.
 	Name() string
 }
@@ -171,7 +246,12 @@ type StorageClient interface {
 // QueueManager manages a queue of samples to be sent to the Storage
 // indicated by the provided StorageClient.
 type QueueManager struct {
+	// pending is the number of appended samples not yet sent, successfully
+	// or not. Accessed atomically, it comes first for 64-bit alignment.
+	pending int64
+
 	cfg            QueueManagerConfig
+	metrics        *queueManagerMetrics
 	externalLabels model.LabelSet
 	relabelConfigs []*config.RelabelConfig
 	client         StorageClient
@@ -180,9 +260,11 @@ type QueueManager struct {
 
 	shardsMtx   sync.Mutex
 	shards      *shards
+	draining    bool // Set once Drain was called, under shardsMtx.
 	numShards   int
 	reshardChan chan int
 	quit        chan struct{}
+	stopOnce    sync.Once
 	wg          sync.WaitGroup
 
 	samplesIn, samplesOut, samplesOutDuration *ewmaRate
@@ -191,15 +273,23 @@ type QueueManager struct {
 
 // NewQueueManager builds a new QueueManager.
 func NewQueueManager(cfg QueueManagerConfig, externalLabels model.LabelSet, relabelConfigs []*config.RelabelConfig, client StorageClient) *QueueManager {
+	// Resharding would flip between both bounds otherwise.
+	if cfg.MinShards > cfg.MaxShards {
+		cfg.MinShards = cfg.MaxShards
+	}
+	if cfg.MinShards < 1 {
+		cfg.MinShards = 1
+	}
 	t := &QueueManager{
 		cfg:            cfg,
+		metrics:        newQueueManagerMetrics(cfg.Registerer),
 		externalLabels: externalLabels,
 		relabelConfigs: relabelConfigs,
 		client:         client,
 		queueName:      client.Name(),
 
 		logLimiter:  rate.NewLimiter(logRateLimit, logBurst),
-		numShards:   1,
+		numShards:   cfg.MinShards,
 		reshardChan: make(chan int),
 		quit:        make(chan struct{}),
 
@@ -208,14 +298,18 @@ func NewQueueManager(cfg QueueManagerConfig, externalLabels model.LabelSet, rela
 		samplesOutDuration: newEWMARate(ewmaWeight, shardUpdateDuration),
 	}
 	t.shards = t.newShards(t.numShards)
-	numShards.WithLabelValues(t.queueName).Set(float64(t.numShards))
+	t.metrics.shards.WithLabelValues(t.queueName).Set(float64(t.numShards))
+	t.metrics.desiredShards.WithLabelValues(t.queueName).Set(float64(t.numShards))
+	t.metrics.maxShards.WithLabelValues(t.queueName).Set(float64(t.cfg.MaxShards))
+	t.metrics.pendingSamples.WithLabelValues(t.queueName)
+	t.metrics.enqueueRetries.WithLabelValues(t.queueName)
 	queueCapacity.WithLabelValues(t.queueName).Set(float64(t.cfg.QueueCapacity))
 
 	return t
 }
 
 // Append queues a sample to be sent to the remote storage. It drops the
-// sample on the floor if the queue is full.
+// sample on the floor if the queue is full or being drained.
 // Always returns nil.
 func (t *QueueManager) Append(s *model.Sample) error {
 	var snew model.Sample
@@ -236,12 +330,21 @@ func (t *QueueManager) Append(s *model.Sample) error {
 	}
 
 	t.shardsMtx.Lock()
-	enqueued := t.shards.enqueue(&snew)
+	draining := t.draining
+	enqueued := !draining && t.shards.enqueue(&snew)
 	t.shardsMtx.Unlock()
 
-	if enqueued {
+	switch {
+	case enqueued:
+		atomic.AddInt64(&t.pending, 1)
+		t.metrics.pendingSamples.WithLabelValues(t.queueName).Inc()
 		queueLength.WithLabelValues(t.queueName).Inc()
-	} else {
+	case draining:
+		droppedSamplesTotal.WithLabelValues(t.queueName).Inc()
+		if t.logLimiter.Allow() {
+			log.Warn("Remote storage queue draining, discarding sample. Multiple subsequent messages of this kind may be suppressed.")
+		}
+	default:
 		droppedSamplesTotal.WithLabelValues(t.queueName).Inc()
 		if t.logLimiter.Allow() {
 			log.Warn("Remote storage queue full, discarding sample. Multiple subsequent messages of this kind may be suppressed.")
@@ -270,16 +373,42 @@ func (t *QueueManager) Start() {
 }
 
 // Stop stops sending samples to the remote storage and waits for pending
-// sends to complete.
+// sends to complete. Calling it more than once, or after Drain, waits for
+// the first call to complete.
 func (t *QueueManager) Stop() {
-	log.Infof("Stopping remote storage...")
-	close(t.quit)
-	t.wg.Wait()
+	t.stopOnce.Do(func() {
+		log.Infof("Stopping remote storage...")
+		close(t.quit)
+		t.wg.Wait()
+
+		t.shardsMtx.Lock()
+		defer t.shardsMtx.Unlock()
+		t.shards.stop()
+		log.Info("Remote storage stopped.")
+	})
+}
 
+// Drain stops accepting new samples and sends all queued ones, returning
+// once they were sent or the context is done. In the latter case, the error
+// reports the number of samples left unsent, which keep being sent in the
+// background. The queue manager is stopped afterwards.
+func (t *QueueManager) Drain(ctx context.Context) error {
 	t.shardsMtx.Lock()
-	defer t.shardsMtx.Unlock()
-	t.shards.stop()
-	log.Info("Remote storage stopped.")
+	t.draining = true
+	t.shardsMtx.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		t.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("remote storage queue not drained, %d samples left: %s", atomic.LoadInt64(&t.pending), ctx.Err())
+	}
 }
 
 func (t *QueueManager) updateShardsLoop() {
@@ -325,6 +454,7 @@ func (t *QueueManager) calculateDesiredShards() {
 	)
 	log.Debugf("QueueManager.calculateDesiredShards samplesIn=%f, samplesOut=%f, samplesPending=%f, desiredShards=%f",
 		samplesIn, samplesOut, samplesPending, desiredShards)
+	t.metrics.desiredShards.WithLabelValues(t.queueName).Set(desiredShards)
 
 	// Changes in the number of shards must be greater than shardToleranceFraction.
 	var (
@@ -339,6 +469,8 @@ func (t *QueueManager) calculateDesiredShards() {
 	numShards := int(math.Ceil(desiredShards))
 	if numShards > t.cfg.MaxShards {
 		numShards = t.cfg.MaxShards
+	} else if numShards < t.cfg.MinShards {
+		numShards = t.cfg.MinShards
 	}
 	if numShards == t.numShards {
 		return
@@ -369,7 +501,7 @@ func (t *QueueManager) reshardLoop() {
 }
 
 func (t *QueueManager) reshard(n int) {
-	numShards.WithLabelValues(t.queueName).Set(float64(n))
+	t.metrics.shards.WithLabelValues(t.queueName).Set(float64(n))
 
 	t.shardsMtx.Lock()
 	newShards := t.newShards(n)
@@ -477,6 +609,8 @@ func (s *shards) runShard(i int) {
 func (s *shards) sendSamples(samples model.Samples) {
 	begin := time.Now()
 	s.sendSamplesWithBackoff(samples)
+	atomic.AddInt64(&s.qm.pending, -int64(len(samples)))
+	s.qm.metrics.pendingSamples.WithLabelValues(s.qm.queueName).Sub(float64(len(samples)))
 
 	// These counters are used to calculate the dynamic sharding, and as such
 	// should be maintained irrespective of success or failure.
@@ -486,10 +620,11 @@ func (s *shards) sendSamples(samples model.Samples) {
 
 // sendSamples to the remote storage with backoff for recoverable errors.
 func (s *shards) sendSamplesWithBackoff(samples model.Samples) {
+	req := toWriteRequest(samples)
 	backoff := s.qm.cfg.MinBackoff
 	for retries := s.qm.cfg.MaxRetries; retries > 0; retries-- {
 		begin := time.Now()
-		err := s.qm.client.Store(samples)
+		err := s.qm.client.Store(context.Background(), req)
 
 		sentBatchDuration.WithLabelValues(s.qm.queueName).Observe(time.Since(begin).Seconds())
 		if err == nil {
@@ -501,6 +636,9 @@ func (s *shards) sendSamplesWithBackoff(samples model.Samples) {
 		if _, ok := err.(recoverableError); !ok {
 			break
 		}
+		if retries > 1 {
+			s.qm.metrics.enqueueRetries.WithLabelValues(s.qm.queueName).Inc()
+		}
 		time.Sleep(backoff)
 		backoff = backoff * 2
 		if backoff > s.qm.cfg.MaxBackoff {
@@ -510,3 +648,31 @@ func (s *shards) sendSamplesWithBackoff(samples model.Samples) {
 
 	failedSamplesTotal.WithLabelValues(s.qm.queueName).Add(float64(len(samples)))
 }
+
+// toWriteRequest converts a batch of samples into a write request, with one
+// time series per sample.
+func toWriteRequest(samples model.Samples) *WriteRequest {
+	req := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(samples)),
+	}
+	for _, s := range samples {
+		ts := &TimeSeries{
+			Labels: make([]*LabelPair, 0, len(s.Metric)),
+		}
+		for k, v := range s.Metric {
+			ts.Labels = append(ts.Labels,
+				&LabelPair{
+					Name:  string(k),
+					Value: string(v),
+				})
+		}
+		ts.Samples = []*Sample{
+			{
+				Value:       float64(s.Value),
+				TimestampMs: int64(s.Timestamp),
+			},
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+	return req
+}
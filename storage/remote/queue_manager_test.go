@@ -15,12 +15,16 @@ package remote
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
 )
 
 type TestStorageClient struct {
@@ -62,15 +66,23 @@ func (c *TestStorageClient) waitForExpectedSamples(t *testing.T) {
 	}
 }
 
-func (c *TestStorageClient) Store(ss model.Samples) error {
+func (c *TestStorageClient) Store(_ context.Context, req *WriteRequest) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	for _, s := range ss {
-		ts := s.Metric.String()
-		c.receivedSamples[ts] = append(c.receivedSamples[ts], s)
+	count := 0
+	for _, ts := range req.Timeseries {
+		metric := labelPairsToMetric(ts.Labels)
+		for _, s := range ts.Samples {
+			c.receivedSamples[metric.String()] = append(c.receivedSamples[metric.String()], &model.Sample{
+				Metric:    metric,
+				Value:     model.SampleValue(s.Value),
+				Timestamp: model.Time(s.TimestampMs),
+			})
+			count++
+		}
 	}
-	c.wg.Add(-len(ss))
+	c.wg.Add(-count)
 	return nil
 }
 
@@ -161,7 +173,7 @@ func NewTestBlockedStorageClient() *TestBlockingStorageClient {
 	}
 }
 
-func (c *TestBlockingStorageClient) Store(s model.Samples) error {
+func (c *TestBlockingStorageClient) Store(_ context.Context, _ *WriteRequest) error {
 	atomic.AddUint64(&c.numCalls, 1)
 	<-c.block
 	return nil
@@ -251,3 +263,201 @@ func TestSpawnNotMoreThanMaxConcurrentSendsGoroutines(t *testing.T) {
 		t.Errorf("Saw %d concurrent sends, expected 1", numCalls)
 	}
 }
+
+func TestDrain(t *testing.T) {
+	n := defaultQueueManagerConfig.MaxSamplesPerSend * 3 / 2
+
+	samples := make(model.Samples, 0, n)
+	for i := 0; i < n; i++ {
+		name := model.LabelValue(fmt.Sprintf("test_metric_%d", i))
+		samples = append(samples, &model.Sample{
+			Metric: model.Metric{
+				model.MetricNameLabel: name,
+			},
+			Value: model.SampleValue(i),
+		})
+	}
+
+	c := NewTestStorageClient()
+	c.expectSamples(samples)
+
+	cfg := defaultQueueManagerConfig
+	cfg.MinShards = 2
+	// Partial batches are only sent by draining.
+	cfg.BatchSendDeadline = time.Hour
+	m := NewQueueManager(cfg, nil, nil, c)
+	m.Start()
+
+	for _, s := range samples {
+		m.Append(s)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Drain(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c.waitForExpectedSamples(t)
+
+	// Samples appended once drained are dropped.
+	m.Append(samples[0])
+	if m.queueLen() != 0 {
+		t.Fatalf("Sample appended after Drain was queued")
+	}
+	m.Stop()
+}
+
+func TestDrainTimeout(t *testing.T) {
+	c := NewTestBlockedStorageClient()
+	cfg := defaultQueueManagerConfig
+	cfg.MaxShards = 1
+	m := NewQueueManager(cfg, nil, nil, c)
+	m.Start()
+
+	for i := 0; i < 10; i++ {
+		m.Append(&model.Sample{
+			Metric: model.Metric{model.MetricNameLabel: "test_metric"},
+			Value:  model.SampleValue(i),
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := m.Drain(ctx)
+	if err == nil || !strings.Contains(err.Error(), "10 samples left") {
+		t.Fatalf("Expected error reporting 10 samples left, got %v", err)
+	}
+
+	c.unlock()
+	m.Stop()
+	if c.NumCalls() != 1 {
+		t.Fatalf("Unexpected number of sends; want 1, got %d", c.NumCalls())
+	}
+}
+
+func TestQueueManagerMetrics(t *testing.T) {
+	c := NewTestBlockedStorageClient()
+	reg := prometheus.NewRegistry()
+	cfg := defaultQueueManagerConfig
+	cfg.MaxShards = 4
+	cfg.Registerer = reg
+	m := NewQueueManager(cfg, nil, nil, c)
+	m.Start()
+
+	gather := func() map[string]float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := map[string]float64{}
+		for _, mf := range mfs {
+			for _, metric := range mf.Metric {
+				switch {
+				case metric.Gauge != nil:
+					values[mf.GetName()] = metric.GetGauge().GetValue()
+				case metric.Counter != nil:
+					values[mf.GetName()] = metric.GetCounter().GetValue()
+				}
+			}
+		}
+		return values
+	}
+
+	// The first batch blocks in Store, so that the rest builds a backlog.
+	n := defaultQueueManagerConfig.MaxSamplesPerSend * 3
+	for i := 0; i < n; i++ {
+		m.Append(&model.Sample{
+			Metric: model.Metric{model.MetricNameLabel: "test_metric"},
+			Value:  model.SampleValue(i),
+		})
+	}
+	for i := 0; i < 100 && c.NumCalls() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	want := map[string]float64{
+		"prometheus_remote_storage_shards":                1,
+		"prometheus_remote_storage_shards_desired":        1,
+		"prometheus_remote_storage_shards_max":            4,
+		"prometheus_remote_storage_pending_samples":       float64(n),
+		"prometheus_remote_storage_enqueue_retries_total": 0,
+	}
+	if got := gather(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected metrics with a backlog; want %v, got %v", want, got)
+	}
+
+	c.unlock()
+	m.Stop()
+	want["prometheus_remote_storage_pending_samples"] = 0
+	if got := gather(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected metrics once sent; want %v, got %v", want, got)
+	}
+}
+
+func TestMinShards(t *testing.T) {
+	c := NewTestStorageClient()
+	cfg := defaultQueueManagerConfig
+	cfg.MinShards = 2
+	m := NewQueueManager(cfg, nil, nil, c)
+
+	if m.numShards != 2 || m.shards.len() != 2 {
+		t.Fatalf("Expected to start with %d shards, got %d", 2, m.shards.len())
+	}
+
+	// A trickle of fast sends would ask for less than a single shard.
+	m.samplesIn.incr(1000)
+	m.samplesOut.incr(1000)
+	m.samplesOutDuration.incr(int64(time.Millisecond))
+	m.calculateDesiredShards()
+
+	if m.numShards != 2 {
+		t.Fatalf("Expected shards not to drop below %d, got %d", 2, m.numShards)
+	}
+}
+
+func TestMinShardsAboveMaxShards(t *testing.T) {
+	c := NewTestStorageClient()
+	cfg := defaultQueueManagerConfig
+	cfg.MinShards = 4
+	cfg.MaxShards = 2
+	m := NewQueueManager(cfg, nil, nil, c)
+
+	if m.numShards != 2 || m.shards.len() != 2 {
+		t.Fatalf("Expected to start with %d shards, got %d", 2, m.shards.len())
+	}
+
+	// Slow sends would ask for more shards than allowed.
+	m.samplesIn.incr(100000)
+	m.samplesOut.incr(100)
+	m.samplesOutDuration.incr(int64(time.Second))
+	m.calculateDesiredShards()
+	if m.numShards != 2 {
+		t.Fatalf("Expected shards to stay at %d, got %d", 2, m.numShards)
+	}
+}
+
+func TestSampleDeliveryTimeout(t *testing.T) {
+	handle, unblock := timeoutFirstRequest()
+	c, reqs, stop := newWriteTestClient(t, &ClientConfig{Timeout: model.Duration(50 * time.Millisecond)}, handle)
+	defer stop()
+	defer unblock()
+
+	cfg := defaultQueueManagerConfig
+	cfg.MaxShards = 1
+	cfg.MaxSamplesPerSend = 1
+	m := NewQueueManager(cfg, nil, nil, c)
+	m.Append(&model.Sample{
+		Metric: model.Metric{model.MetricNameLabel: "test_metric"},
+		Value:  1,
+	})
+	m.Start()
+	defer m.Stop()
+
+	// The samples are sent again, rather than failed, after timing out.
+	select {
+	case req := <-reqs:
+		if len(req.Timeseries) != 1 || req.Timeseries[0].Labels[0].Value != "test_metric" {
+			t.Fatalf("Unexpected write request: %v", req)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the samples to be sent again")
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"golang.org/x/net/context"
 
@@ -36,10 +37,11 @@ type Reader struct {
 func (r *Reader) ApplyConfig(conf *config.Config) error {
 	clients := []*Client{}
 	for i, rrConf := range conf.RemoteReadConfigs {
-		c, err := NewClient(i, &clientConfig{
-			url:              rrConf.URL,
-			timeout:          rrConf.RemoteTimeout,
-			httpClientConfig: rrConf.HTTPClientConfig,
+		c, err := NewClient(i, &ClientConfig{
+			URL:              rrConf.URL,
+			Timeout:          rrConf.RemoteTimeout,
+			HTTPClientConfig: rrConf.HTTPClientConfig,
+			Registerer:       prometheus.DefaultRegisterer,
 		})
 		if err != nil {
 			return err
@@ -72,9 +74,9 @@ func (r *Reader) Queriers() []local.Querier {
 	return queriers
 }
 
-// querier is an adapter to make a Client usable as a promql.Querier.
+// querier is an adapter to make a ReadClient usable as a promql.Querier.
 type querier struct {
-	client         *Client
+	client         ReadClient
 	externalLabels model.LabelSet
 }
 
@@ -89,13 +91,19 @@ func (q *querier) QueryInstant(ctx context.Context, ts model.Time, stalenessDelt
 func (q *querier) read(ctx context.Context, from, through model.Time, matchers metric.LabelMatchers) (model.Matrix, error) {
 	m, added := q.addExternalLabels(matchers)
 
-	res, err := q.client.Read(ctx, from, through, m)
+	query := &Query{
+		StartTimestampMs: int64(from),
+		EndTimestampMs:   int64(through),
+		Matchers:         labelMatchersToProto(m),
+	}
+	res, err := q.client.Read(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	removeLabels(res, added)
-	return res, err
+	matrix := matrixFromProto(res.Timeseries)
+	removeLabels(matrix, added)
+	return matrix, nil
 }
 
 // addExternalLabels adds matchers for each external label. External labels
@@ -0,0 +1,107 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// splitQuery splits the time range of a query into consecutive, non
+// overlapping ranges of at most the chunk duration, the last one taking up
+// what is left of the range. Both ends of a range are inclusive.
+func splitQuery(query *Query, chunk time.Duration) []*Query {
+	step := int64(chunk / time.Millisecond)
+	if step <= 0 || query.EndTimestampMs-query.StartTimestampMs < step {
+		return []*Query{query}
+	}
+
+	var queries []*Query
+	for start := query.StartTimestampMs; ; start += step {
+		end := start + step - 1
+		if start+step >= query.EndTimestampMs {
+			end = query.EndTimestampMs
+		}
+		q := *query
+		q.StartTimestampMs = start
+		q.EndTimestampMs = end
+		queries = append(queries, &q)
+		if end == query.EndTimestampMs {
+			return queries
+		}
+	}
+}
+
+// mergeQueryResults merges the series of results, in the order they first
+// appear. The samples and histograms of series with the same labels are
+// sorted by time, keeping only the first of those with the same timestamp.
+func mergeQueryResults(results []*QueryResult) *QueryResult {
+	var (
+		merged = &QueryResult{}
+		series = map[string]*TimeSeries{}
+	)
+	for _, res := range results {
+		for _, ts := range res.Timeseries {
+			key := labelPairsKey(ts.Labels)
+			m, ok := series[key]
+			if !ok {
+				m = &TimeSeries{Labels: ts.Labels}
+				series[key] = m
+				merged.Timeseries = append(merged.Timeseries, m)
+			}
+			m.Samples = append(m.Samples, ts.Samples...)
+			m.Histograms = append(m.Histograms, ts.Histograms...)
+			m.Exemplars = append(m.Exemplars, ts.Exemplars...)
+		}
+	}
+
+	for _, ts := range merged.Timeseries {
+		sort.SliceStable(ts.Samples, func(i, j int) bool {
+			return ts.Samples[i].TimestampMs < ts.Samples[j].TimestampMs
+		})
+		samples := ts.Samples[:0]
+		for i, s := range ts.Samples {
+			if i > 0 && s.TimestampMs == samples[len(samples)-1].TimestampMs {
+				continue
+			}
+			samples = append(samples, s)
+		}
+		ts.Samples = samples
+
+		sort.SliceStable(ts.Histograms, func(i, j int) bool {
+			return ts.Histograms[i].TimestampMs < ts.Histograms[j].TimestampMs
+		})
+		histograms := ts.Histograms[:0]
+		for i, h := range ts.Histograms {
+			if i > 0 && h.TimestampMs == histograms[len(histograms)-1].TimestampMs {
+				continue
+			}
+			histograms = append(histograms, h)
+		}
+		ts.Histograms = histograms
+	}
+	return merged
+}
+
+// labelPairsKey returns a key identifying a set of labels, independent of
+// their order.
+func labelPairsKey(labels []*LabelPair) string {
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, l.Name+"\xff"+l.Value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\xfe")
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestSplitQuery(t *testing.T) {
+	for i, test := range []struct {
+		start, end int64
+		chunk      time.Duration
+		want       [][2]int64
+	}{
+		{start: 0, end: 100, chunk: 0, want: [][2]int64{{0, 100}}},
+		{start: 0, end: 100, chunk: time.Second, want: [][2]int64{{0, 100}}},
+		{start: 0, end: 100, chunk: 50 * time.Millisecond, want: [][2]int64{{0, 49}, {50, 100}}},
+		{start: 0, end: 120, chunk: 50 * time.Millisecond, want: [][2]int64{{0, 49}, {50, 99}, {100, 120}}},
+	} {
+		var got [][2]int64
+		for _, q := range splitQuery(&Query{StartTimestampMs: test.start, EndTimestampMs: test.end}, test.chunk) {
+			got = append(got, [2]int64{q.StartTimestampMs, q.EndTimestampMs})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%d. Unexpected sub-queries; want %v, got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestClientReadChunked(t *testing.T) {
+	const hour = int64(time.Hour / time.Millisecond)
+
+	var queries []*Query
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			compressed, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			reqBuf, err := snappy.Decode(nil, compressed)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var req ReadRequest
+			if err := proto.Unmarshal(reqBuf, &req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			q := req.Queries[0]
+			queries = append(queries, q)
+
+			// Hourly samples, including the one before the range like a
+			// lookback would, so that sub-query results overlap.
+			a := &TimeSeries{Labels: []*LabelPair{{Name: "job", Value: "a"}}}
+			for ts := q.StartTimestampMs - hour; ts <= q.EndTimestampMs; ts += hour {
+				if ts >= 0 {
+					a.Samples = append(a.Samples, &Sample{Value: float64(ts / hour), TimestampMs: ts})
+				}
+			}
+			result := &QueryResult{Timeseries: []*TimeSeries{a}}
+			// A series only present in the last sub-query.
+			if q.EndTimestampMs == 24*hour {
+				result.Timeseries = append([]*TimeSeries{{
+					Labels:  []*LabelPair{{Name: "job", Value: "b"}},
+					Samples: []*Sample{{Value: 1, TimestampMs: 20 * hour}},
+				}}, result.Timeseries...)
+			}
+
+			data, err := proto.Marshal(&ReadResponse{Results: []*QueryResult{result}})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			w.Header().Set("Content-Encoding", "snappy")
+			w.Write(snappy.Encode(nil, data))
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+	c, err := NewClient(0, &ClientConfig{
+		URL:               &config.URL{URL: serverURL},
+		Timeout:           model.Duration(time.Second),
+		ReadChunkDuration: model.Duration(6 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matchers := []*LabelMatcher{{Type: MatchType_REGEX_MATCH, Name: "job", Value: ".+"}}
+	res, err := c.Read(context.Background(), &Query{
+		StartTimestampMs: 0,
+		EndTimestampMs:   24 * hour,
+		Matchers:         matchers,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantQueries := []*Query{
+		{StartTimestampMs: 0, EndTimestampMs: 6*hour - 1, Matchers: matchers},
+		{StartTimestampMs: 6 * hour, EndTimestampMs: 12*hour - 1, Matchers: matchers},
+		{StartTimestampMs: 12 * hour, EndTimestampMs: 18*hour - 1, Matchers: matchers},
+		{StartTimestampMs: 18 * hour, EndTimestampMs: 24 * hour, Matchers: matchers},
+	}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Fatalf("Unexpected sub-queries; want %v, got %v", wantQueries, queries)
+	}
+
+	a := &TimeSeries{Labels: []*LabelPair{{Name: "job", Value: "a"}}}
+	for h := int64(0); h <= 24; h++ {
+		a.Samples = append(a.Samples, &Sample{Value: float64(h), TimestampMs: h * hour})
+	}
+	want := &QueryResult{Timeseries: []*TimeSeries{
+		a,
+		{
+			Labels:  []*LabelPair{{Name: "job", Value: "b"}},
+			Samples: []*Sample{{Value: 1, TimestampMs: 20 * hour}},
+		},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("Unexpected result; want %v, got %v", want, res)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// RequestRecorder is given every write request attempt of a client right
+// before it is sent, for debugging. The body is compressed, as sent. The
+// header must not be modified, and the body is only valid during the call.
+// Headers set by the HTTP transport, such as those for authentication,
+// are not included.
+type RequestRecorder interface {
+	RecordRequest(method, url string, header http.Header, body []byte) error
+}
+
+// recordedRequest is the JSON object a request is recorded as by a
+// FileRecorder.
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// FileRecorder is a RequestRecorder writing requests to a file, one JSON
+// object per line, to be replayed with ReplayFile.
+type FileRecorder struct {
+	mtx  sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileRecorder returns a FileRecorder appending to the file at the given
+// path, creating it if needed.
+func NewFileRecorder(path string) (*FileRecorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileRecorder{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// RecordRequest implements RequestRecorder.
+func (r *FileRecorder) RecordRequest(method, url string, header http.Header, body []byte) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.enc.Encode(&recordedRequest{Method: method, URL: url, Header: header, Body: body})
+}
+
+// Close closes the file.
+func (r *FileRecorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.file.Close()
+}
+
+// ReplayFile sends the requests recorded by a FileRecorder at the given path
+// again, one after another, with their recorded method, headers and body.
+// They are sent to the write URL of the client, whatever URL they were
+// recorded for, so that requests recorded against one server can be
+// replayed against another. Replaying stops at the first request failing,
+// which is not retried.
+func ReplayFile(ctx context.Context, c *Client, path string) error {
+	if c.url == nil {
+		return errNoWriteURL
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for i := 0; ; i++ {
+		var rec recordedRequest
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading recorded request %d: %s", i, err)
+		}
+		if err := c.replay(ctx, &rec); err != nil {
+			return fmt.Errorf("replaying recorded request %d: %w", i, err)
+		}
+	}
+}
+
+func (c *Client) replay(ctx context.Context, rec *recordedRequest) error {
+	httpReq, err := http.NewRequest(rec.Method, c.url.String(), bytes.NewReader(rec.Body))
+	if err != nil {
+		return err
+	}
+	if rec.Header != nil {
+		httpReq.Header = rec.Header
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	httpResp, err := c.do(ctx, c.url, httpReq)
+	if err != nil {
+		return err
+	}
+	defer drainBody(httpResp.Body, c.maxErrMsgLen)
+	if httpResp.StatusCode/100 != 2 {
+		return c.newHTTPError(httpResp, false)
+	}
+	return nil
+}
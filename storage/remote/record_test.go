@@ -0,0 +1,85 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote_record_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "requests.json")
+
+	recorder, err := NewFileRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, reqs, stop := newWriteTestClient(t, &ClientConfig{RequestRecorder: recorder}, nil)
+	defer stop()
+
+	var want []*WriteRequest
+	for i := 0; i < 2; i++ {
+		req := toWriteRequest(model.Samples{{
+			Metric: model.Metric{model.MetricNameLabel: "test_metric"},
+			Value:  model.SampleValue(i),
+		}})
+		if err := c.Store(context.Background(), req); err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		want = append(want, <-reqs)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, replayed, stop := newWriteTestClient(t, &ClientConfig{}, nil)
+	defer stop()
+	if err := ReplayFile(context.Background(), replayer, path); err != nil {
+		t.Fatalf("Unexpected error replaying: %v", err)
+	}
+	for i, w := range want {
+		select {
+		case got := <-replayed:
+			if !reflect.DeepEqual(got, w) {
+				t.Fatalf("%d. Unexpected replayed request; want %v, got %v", i, w, got)
+			}
+		default:
+			t.Fatalf("%d. Expected request to be replayed", i)
+		}
+	}
+
+	failing, _, stop := newWriteTestClient(t, &ClientConfig{}, func(w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, "test error", http.StatusBadRequest)
+		return false
+	})
+	defer stop()
+	err = ReplayFile(context.Background(), failing, path)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 HTTPError replaying, got %v", err)
+	}
+}
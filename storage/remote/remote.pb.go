@@ -12,12 +12,32 @@ It has these top-level messages:
 	Sample
 	LabelPair
 	TimeSeries
+	Exemplar
+	Histogram
+	BucketSpan
 	WriteRequest
+	WriteRequestV2
+	TimeSeriesV2
+	ExemplarV2
+	MetadataV2
+	MetricMetadata
 	ReadRequest
 	ReadResponse
 	Query
 	LabelMatcher
 	QueryResult
+	LabelNamesRequest
+	LabelNamesResponse
+	LabelValuesRequest
+	LabelValuesResponse
+	SeriesRequest
+	SeriesResponse
+	LabelSet
+	ExemplarsRequest
+	ExemplarsResponse
+	ChunkedReadResponse
+	ChunkedSeries
+	Chunk
 */
 package remote
 
@@ -63,6 +83,93 @@ func (x MatchType) String() string {
 }
 func (MatchType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+type MetricMetadata_MetricType int32
+
+const (
+	MetricMetadata_UNKNOWN        MetricMetadata_MetricType = 0
+	MetricMetadata_COUNTER        MetricMetadata_MetricType = 1
+	MetricMetadata_GAUGE          MetricMetadata_MetricType = 2
+	MetricMetadata_HISTOGRAM      MetricMetadata_MetricType = 3
+	MetricMetadata_GAUGEHISTOGRAM MetricMetadata_MetricType = 4
+	MetricMetadata_SUMMARY        MetricMetadata_MetricType = 5
+	MetricMetadata_INFO           MetricMetadata_MetricType = 6
+	MetricMetadata_STATESET       MetricMetadata_MetricType = 7
+)
+
+var MetricMetadata_MetricType_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "COUNTER",
+	2: "GAUGE",
+	3: "HISTOGRAM",
+	4: "GAUGEHISTOGRAM",
+	5: "SUMMARY",
+	6: "INFO",
+	7: "STATESET",
+}
+var MetricMetadata_MetricType_value = map[string]int32{
+	"UNKNOWN":        0,
+	"COUNTER":        1,
+	"GAUGE":          2,
+	"HISTOGRAM":      3,
+	"GAUGEHISTOGRAM": 4,
+	"SUMMARY":        5,
+	"INFO":           6,
+	"STATESET":       7,
+}
+
+func (x MetricMetadata_MetricType) String() string {
+	return proto.EnumName(MetricMetadata_MetricType_name, int32(x))
+}
+func (MetricMetadata_MetricType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{11, 0}
+}
+
+type ReadRequest_ResponseType int32
+
+const (
+	// Server responds with a single snappy-compressed ReadResponse.
+	ReadRequest_SAMPLES ReadRequest_ResponseType = 0
+	// Server streams ChunkedReadResponse frames holding XOR-encoded chunks.
+	ReadRequest_STREAMED_XOR_CHUNKS ReadRequest_ResponseType = 1
+)
+
+var ReadRequest_ResponseType_name = map[int32]string{
+	0: "SAMPLES",
+	1: "STREAMED_XOR_CHUNKS",
+}
+var ReadRequest_ResponseType_value = map[string]int32{
+	"SAMPLES":             0,
+	"STREAMED_XOR_CHUNKS": 1,
+}
+
+func (x ReadRequest_ResponseType) String() string {
+	return proto.EnumName(ReadRequest_ResponseType_name, int32(x))
+}
+func (ReadRequest_ResponseType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{12, 0}
+}
+
+type Chunk_Encoding int32
+
+const (
+	Chunk_UNKNOWN Chunk_Encoding = 0
+	Chunk_XOR     Chunk_Encoding = 1
+)
+
+var Chunk_Encoding_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "XOR",
+}
+var Chunk_Encoding_value = map[string]int32{
+	"UNKNOWN": 0,
+	"XOR":     1,
+}
+
+func (x Chunk_Encoding) String() string {
+	return proto.EnumName(Chunk_Encoding_name, int32(x))
+}
+func (Chunk_Encoding) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{28, 0} }
+
 type Sample struct {
 	Value       float64 `protobuf:"fixed64,1,opt,name=value" json:"value,omitempty"`
 	TimestampMs int64   `protobuf:"varint,2,opt,name=timestamp_ms,json=timestampMs" json:"timestamp_ms,omitempty"`
@@ -114,7 +221,9 @@ func (m *LabelPair) GetValue() string {
 type TimeSeries struct {
 	Labels []*LabelPair `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
 	// Sorted by time, oldest sample first.
-	Samples []*Sample `protobuf:"bytes,2,rep,name=samples" json:"samples,omitempty"`
+	Samples    []*Sample    `protobuf:"bytes,2,rep,name=samples" json:"samples,omitempty"`
+	Exemplars  []*Exemplar  `protobuf:"bytes,3,rep,name=exemplars" json:"exemplars,omitempty"`
+	Histograms []*Histogram `protobuf:"bytes,4,rep,name=histograms" json:"histograms,omitempty"`
 }
 
 func (m *TimeSeries) Reset()                    { *m = TimeSeries{} }
@@ -136,14 +245,211 @@ func (m *TimeSeries) GetSamples() []*Sample {
 	return nil
 }
 
+func (m *TimeSeries) GetExemplars() []*Exemplar {
+	if m != nil {
+		return m.Exemplars
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetHistograms() []*Histogram {
+	if m != nil {
+		return m.Histograms
+	}
+	return nil
+}
+
+type Exemplar struct {
+	// Labels identifying the exemplar, such as a trace ID.
+	Labels      []*LabelPair `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+	Value       float64      `protobuf:"fixed64,2,opt,name=value" json:"value,omitempty"`
+	TimestampMs int64        `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs" json:"timestamp_ms,omitempty"`
+}
+
+func (m *Exemplar) Reset()                    { *m = Exemplar{} }
+func (m *Exemplar) String() string            { return proto.CompactTextString(m) }
+func (*Exemplar) ProtoMessage()               {}
+func (*Exemplar) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *Exemplar) GetLabels() []*LabelPair {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Exemplar) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *Exemplar) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
+// Histogram is a native histogram. Integer histograms carry their buckets as
+// deltas, float histograms as absolute counts.
+type Histogram struct {
+	CountInt   uint64  `protobuf:"varint,1,opt,name=count_int,json=countInt" json:"count_int,omitempty"`
+	CountFloat float64 `protobuf:"fixed64,2,opt,name=count_float,json=countFloat" json:"count_float,omitempty"`
+	Sum        float64 `protobuf:"fixed64,3,opt,name=sum" json:"sum,omitempty"`
+	// Resolution of the buckets, from -4 (coarsest) to 8 (finest).
+	Schema         int32         `protobuf:"zigzag32,4,opt,name=schema" json:"schema,omitempty"`
+	ZeroThreshold  float64       `protobuf:"fixed64,5,opt,name=zero_threshold,json=zeroThreshold" json:"zero_threshold,omitempty"`
+	ZeroCountInt   uint64        `protobuf:"varint,6,opt,name=zero_count_int,json=zeroCountInt" json:"zero_count_int,omitempty"`
+	ZeroCountFloat float64       `protobuf:"fixed64,7,opt,name=zero_count_float,json=zeroCountFloat" json:"zero_count_float,omitempty"`
+	NegativeSpans  []*BucketSpan `protobuf:"bytes,8,rep,name=negative_spans,json=negativeSpans" json:"negative_spans,omitempty"`
+	NegativeDeltas []int64       `protobuf:"zigzag64,9,rep,packed,name=negative_deltas,json=negativeDeltas" json:"negative_deltas,omitempty"`
+	NegativeCounts []float64     `protobuf:"fixed64,10,rep,packed,name=negative_counts,json=negativeCounts" json:"negative_counts,omitempty"`
+	PositiveSpans  []*BucketSpan `protobuf:"bytes,11,rep,name=positive_spans,json=positiveSpans" json:"positive_spans,omitempty"`
+	PositiveDeltas []int64       `protobuf:"zigzag64,12,rep,packed,name=positive_deltas,json=positiveDeltas" json:"positive_deltas,omitempty"`
+	PositiveCounts []float64     `protobuf:"fixed64,13,rep,packed,name=positive_counts,json=positiveCounts" json:"positive_counts,omitempty"`
+	TimestampMs    int64         `protobuf:"varint,15,opt,name=timestamp_ms,json=timestampMs" json:"timestamp_ms,omitempty"`
+}
+
+func (m *Histogram) Reset()                    { *m = Histogram{} }
+func (m *Histogram) String() string            { return proto.CompactTextString(m) }
+func (*Histogram) ProtoMessage()               {}
+func (*Histogram) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *Histogram) GetCountInt() uint64 {
+	if m != nil {
+		return m.CountInt
+	}
+	return 0
+}
+
+func (m *Histogram) GetCountFloat() float64 {
+	if m != nil {
+		return m.CountFloat
+	}
+	return 0
+}
+
+func (m *Histogram) GetSum() float64 {
+	if m != nil {
+		return m.Sum
+	}
+	return 0
+}
+
+func (m *Histogram) GetSchema() int32 {
+	if m != nil {
+		return m.Schema
+	}
+	return 0
+}
+
+func (m *Histogram) GetZeroThreshold() float64 {
+	if m != nil {
+		return m.ZeroThreshold
+	}
+	return 0
+}
+
+func (m *Histogram) GetZeroCountInt() uint64 {
+	if m != nil {
+		return m.ZeroCountInt
+	}
+	return 0
+}
+
+func (m *Histogram) GetZeroCountFloat() float64 {
+	if m != nil {
+		return m.ZeroCountFloat
+	}
+	return 0
+}
+
+func (m *Histogram) GetNegativeSpans() []*BucketSpan {
+	if m != nil {
+		return m.NegativeSpans
+	}
+	return nil
+}
+
+func (m *Histogram) GetNegativeDeltas() []int64 {
+	if m != nil {
+		return m.NegativeDeltas
+	}
+	return nil
+}
+
+func (m *Histogram) GetNegativeCounts() []float64 {
+	if m != nil {
+		return m.NegativeCounts
+	}
+	return nil
+}
+
+func (m *Histogram) GetPositiveSpans() []*BucketSpan {
+	if m != nil {
+		return m.PositiveSpans
+	}
+	return nil
+}
+
+func (m *Histogram) GetPositiveDeltas() []int64 {
+	if m != nil {
+		return m.PositiveDeltas
+	}
+	return nil
+}
+
+func (m *Histogram) GetPositiveCounts() []float64 {
+	if m != nil {
+		return m.PositiveCounts
+	}
+	return nil
+}
+
+func (m *Histogram) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
+// BucketSpan describes a run of consecutive buckets of a native histogram.
+type BucketSpan struct {
+	// Gap to the previous span, or the index of the first bucket.
+	Offset int32  `protobuf:"zigzag32,1,opt,name=offset" json:"offset,omitempty"`
+	Length uint32 `protobuf:"varint,2,opt,name=length" json:"length,omitempty"`
+}
+
+func (m *BucketSpan) Reset()                    { *m = BucketSpan{} }
+func (m *BucketSpan) String() string            { return proto.CompactTextString(m) }
+func (*BucketSpan) ProtoMessage()               {}
+func (*BucketSpan) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *BucketSpan) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *BucketSpan) GetLength() uint32 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
 type WriteRequest struct {
-	Timeseries []*TimeSeries `protobuf:"bytes,1,rep,name=timeseries" json:"timeseries,omitempty"`
+	Timeseries []*TimeSeries     `protobuf:"bytes,1,rep,name=timeseries" json:"timeseries,omitempty"`
+	Metadata   []*MetricMetadata `protobuf:"bytes,3,rep,name=metadata" json:"metadata,omitempty"`
 }
 
 func (m *WriteRequest) Reset()                    { *m = WriteRequest{} }
 func (m *WriteRequest) String() string            { return proto.CompactTextString(m) }
 func (*WriteRequest) ProtoMessage()               {}
-func (*WriteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*WriteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *WriteRequest) GetTimeseries() []*TimeSeries {
 	if m != nil {
@@ -152,14 +458,206 @@ func (m *WriteRequest) GetTimeseries() []*TimeSeries {
 	return nil
 }
 
+func (m *WriteRequest) GetMetadata() []*MetricMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+// WriteRequestV2 is the write request of the remote write 2.0 protocol. All
+// strings are interned in the symbol table and referenced by index.
+type WriteRequestV2 struct {
+	// Field numbers 1 to 3 are left unused to tell the message apart from a
+	// WriteRequest. The first symbol is always the empty string.
+	Symbols    []string        `protobuf:"bytes,4,rep,name=symbols" json:"symbols,omitempty"`
+	Timeseries []*TimeSeriesV2 `protobuf:"bytes,5,rep,name=timeseries" json:"timeseries,omitempty"`
+}
+
+func (m *WriteRequestV2) Reset()                    { *m = WriteRequestV2{} }
+func (m *WriteRequestV2) String() string            { return proto.CompactTextString(m) }
+func (*WriteRequestV2) ProtoMessage()               {}
+func (*WriteRequestV2) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *WriteRequestV2) GetSymbols() []string {
+	if m != nil {
+		return m.Symbols
+	}
+	return nil
+}
+
+func (m *WriteRequestV2) GetTimeseries() []*TimeSeriesV2 {
+	if m != nil {
+		return m.Timeseries
+	}
+	return nil
+}
+
+type TimeSeriesV2 struct {
+	// Pairs of symbol references, holding label names and values in turn.
+	LabelsRefs []uint32 `protobuf:"varint,1,rep,packed,name=labels_refs,json=labelsRefs" json:"labels_refs,omitempty"`
+	// Sorted by time, oldest sample first.
+	Samples    []*Sample     `protobuf:"bytes,2,rep,name=samples" json:"samples,omitempty"`
+	Histograms []*Histogram  `protobuf:"bytes,3,rep,name=histograms" json:"histograms,omitempty"`
+	Exemplars  []*ExemplarV2 `protobuf:"bytes,4,rep,name=exemplars" json:"exemplars,omitempty"`
+	Metadata   *MetadataV2   `protobuf:"bytes,5,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *TimeSeriesV2) Reset()                    { *m = TimeSeriesV2{} }
+func (m *TimeSeriesV2) String() string            { return proto.CompactTextString(m) }
+func (*TimeSeriesV2) ProtoMessage()               {}
+func (*TimeSeriesV2) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *TimeSeriesV2) GetLabelsRefs() []uint32 {
+	if m != nil {
+		return m.LabelsRefs
+	}
+	return nil
+}
+
+func (m *TimeSeriesV2) GetSamples() []*Sample {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+func (m *TimeSeriesV2) GetHistograms() []*Histogram {
+	if m != nil {
+		return m.Histograms
+	}
+	return nil
+}
+
+func (m *TimeSeriesV2) GetExemplars() []*ExemplarV2 {
+	if m != nil {
+		return m.Exemplars
+	}
+	return nil
+}
+
+func (m *TimeSeriesV2) GetMetadata() *MetadataV2 {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+type ExemplarV2 struct {
+	LabelsRefs  []uint32 `protobuf:"varint,1,rep,packed,name=labels_refs,json=labelsRefs" json:"labels_refs,omitempty"`
+	Value       float64  `protobuf:"fixed64,2,opt,name=value" json:"value,omitempty"`
+	TimestampMs int64    `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs" json:"timestamp_ms,omitempty"`
+}
+
+func (m *ExemplarV2) Reset()                    { *m = ExemplarV2{} }
+func (m *ExemplarV2) String() string            { return proto.CompactTextString(m) }
+func (*ExemplarV2) ProtoMessage()               {}
+func (*ExemplarV2) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ExemplarV2) GetLabelsRefs() []uint32 {
+	if m != nil {
+		return m.LabelsRefs
+	}
+	return nil
+}
+
+func (m *ExemplarV2) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *ExemplarV2) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
+type MetadataV2 struct {
+	Type    MetricMetadata_MetricType `protobuf:"varint,1,opt,name=type,enum=remote.MetricMetadata_MetricType" json:"type,omitempty"`
+	HelpRef uint32                    `protobuf:"varint,3,opt,name=help_ref,json=helpRef" json:"help_ref,omitempty"`
+	UnitRef uint32                    `protobuf:"varint,4,opt,name=unit_ref,json=unitRef" json:"unit_ref,omitempty"`
+}
+
+func (m *MetadataV2) Reset()                    { *m = MetadataV2{} }
+func (m *MetadataV2) String() string            { return proto.CompactTextString(m) }
+func (*MetadataV2) ProtoMessage()               {}
+func (*MetadataV2) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *MetadataV2) GetType() MetricMetadata_MetricType {
+	if m != nil {
+		return m.Type
+	}
+	return MetricMetadata_UNKNOWN
+}
+
+func (m *MetadataV2) GetHelpRef() uint32 {
+	if m != nil {
+		return m.HelpRef
+	}
+	return 0
+}
+
+func (m *MetadataV2) GetUnitRef() uint32 {
+	if m != nil {
+		return m.UnitRef
+	}
+	return 0
+}
+
+type MetricMetadata struct {
+	Type             MetricMetadata_MetricType `protobuf:"varint,1,opt,name=type,enum=remote.MetricMetadata_MetricType" json:"type,omitempty"`
+	MetricFamilyName string                    `protobuf:"bytes,2,opt,name=metric_family_name,json=metricFamilyName" json:"metric_family_name,omitempty"`
+	Help             string                    `protobuf:"bytes,4,opt,name=help" json:"help,omitempty"`
+	Unit             string                    `protobuf:"bytes,5,opt,name=unit" json:"unit,omitempty"`
+}
+
+func (m *MetricMetadata) Reset()                    { *m = MetricMetadata{} }
+func (m *MetricMetadata) String() string            { return proto.CompactTextString(m) }
+func (*MetricMetadata) ProtoMessage()               {}
+func (*MetricMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *MetricMetadata) GetType() MetricMetadata_MetricType {
+	if m != nil {
+		return m.Type
+	}
+	return MetricMetadata_UNKNOWN
+}
+
+func (m *MetricMetadata) GetMetricFamilyName() string {
+	if m != nil {
+		return m.MetricFamilyName
+	}
+	return ""
+}
+
+func (m *MetricMetadata) GetHelp() string {
+	if m != nil {
+		return m.Help
+	}
+	return ""
+}
+
+func (m *MetricMetadata) GetUnit() string {
+	if m != nil {
+		return m.Unit
+	}
+	return ""
+}
+
 type ReadRequest struct {
 	Queries []*Query `protobuf:"bytes,1,rep,name=queries" json:"queries,omitempty"`
+	// Response types accepted by the client, in order of preference. An empty
+	// list means SAMPLES.
+	AcceptedResponseTypes []ReadRequest_ResponseType `protobuf:"varint,2,rep,packed,name=accepted_response_types,json=acceptedResponseTypes,enum=remote.ReadRequest_ResponseType" json:"accepted_response_types,omitempty"`
 }
 
 func (m *ReadRequest) Reset()                    { *m = ReadRequest{} }
 func (m *ReadRequest) String() string            { return proto.CompactTextString(m) }
 func (*ReadRequest) ProtoMessage()               {}
-func (*ReadRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*ReadRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *ReadRequest) GetQueries() []*Query {
 	if m != nil {
@@ -168,6 +666,13 @@ func (m *ReadRequest) GetQueries() []*Query {
 	return nil
 }
 
+func (m *ReadRequest) GetAcceptedResponseTypes() []ReadRequest_ResponseType {
+	if m != nil {
+		return m.AcceptedResponseTypes
+	}
+	return nil
+}
+
 type ReadResponse struct {
 	// In same order as the request's queries.
 	Results []*QueryResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
@@ -176,7 +681,7 @@ type ReadResponse struct {
 func (m *ReadResponse) Reset()                    { *m = ReadResponse{} }
 func (m *ReadResponse) String() string            { return proto.CompactTextString(m) }
 func (*ReadResponse) ProtoMessage()               {}
-func (*ReadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*ReadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *ReadResponse) GetResults() []*QueryResult {
 	if m != nil {
@@ -194,7 +699,7 @@ type Query struct {
 func (m *Query) Reset()                    { *m = Query{} }
 func (m *Query) String() string            { return proto.CompactTextString(m) }
 func (*Query) ProtoMessage()               {}
-func (*Query) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Query) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *Query) GetStartTimestampMs() int64 {
 	if m != nil {
@@ -226,7 +731,7 @@ type LabelMatcher struct {
 func (m *LabelMatcher) Reset()                    { *m = LabelMatcher{} }
 func (m *LabelMatcher) String() string            { return proto.CompactTextString(m) }
 func (*LabelMatcher) ProtoMessage()               {}
-func (*LabelMatcher) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*LabelMatcher) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *LabelMatcher) GetType() MatchType {
 	if m != nil {
@@ -256,7 +761,7 @@ type QueryResult struct {
 func (m *QueryResult) Reset()                    { *m = QueryResult{} }
 func (m *QueryResult) String() string            { return proto.CompactTextString(m) }
 func (*QueryResult) ProtoMessage()               {}
-func (*QueryResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*QueryResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *QueryResult) GetTimeseries() []*TimeSeries {
 	if m != nil {
@@ -265,48 +770,469 @@ func (m *QueryResult) GetTimeseries() []*TimeSeries {
 	return nil
 }
 
+type LabelNamesRequest struct {
+	// Only label names of series matching all matchers are returned. An empty
+	// list matches all series.
+	Matchers []*LabelMatcher `protobuf:"bytes,1,rep,name=matchers" json:"matchers,omitempty"`
+}
+
+func (m *LabelNamesRequest) Reset()                    { *m = LabelNamesRequest{} }
+func (m *LabelNamesRequest) String() string            { return proto.CompactTextString(m) }
+func (*LabelNamesRequest) ProtoMessage()               {}
+func (*LabelNamesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *LabelNamesRequest) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+type LabelNamesResponse struct {
+	LabelNames []string `protobuf:"bytes,1,rep,name=label_names,json=labelNames" json:"label_names,omitempty"`
+}
+
+func (m *LabelNamesResponse) Reset()                    { *m = LabelNamesResponse{} }
+func (m *LabelNamesResponse) String() string            { return proto.CompactTextString(m) }
+func (*LabelNamesResponse) ProtoMessage()               {}
+func (*LabelNamesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *LabelNamesResponse) GetLabelNames() []string {
+	if m != nil {
+		return m.LabelNames
+	}
+	return nil
+}
+
+type LabelValuesRequest struct {
+	LabelName string `protobuf:"bytes,1,opt,name=label_name,json=labelName" json:"label_name,omitempty"`
+	// Only values of series matching all matchers are returned. An empty list
+	// matches all series.
+	Matchers []*LabelMatcher `protobuf:"bytes,2,rep,name=matchers" json:"matchers,omitempty"`
+	// Time range to consider, in milliseconds. Zero values leave the
+	// respective end of the range open.
+	StartTimestampMs int64 `protobuf:"varint,3,opt,name=start_timestamp_ms,json=startTimestampMs" json:"start_timestamp_ms,omitempty"`
+	EndTimestampMs   int64 `protobuf:"varint,4,opt,name=end_timestamp_ms,json=endTimestampMs" json:"end_timestamp_ms,omitempty"`
+	// Maximum number of values returned, if positive. Servers then return a
+	// page token to get the next values with, unless there are none left.
+	Limit int64 `protobuf:"varint,5,opt,name=limit" json:"limit,omitempty"`
+	// Page token of a previous response to continue from. Empty for the
+	// first page.
+	PageToken string `protobuf:"bytes,6,opt,name=page_token,json=pageToken" json:"page_token,omitempty"`
+}
+
+func (m *LabelValuesRequest) Reset()                    { *m = LabelValuesRequest{} }
+func (m *LabelValuesRequest) String() string            { return proto.CompactTextString(m) }
+func (*LabelValuesRequest) ProtoMessage()               {}
+func (*LabelValuesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *LabelValuesRequest) GetLabelName() string {
+	if m != nil {
+		return m.LabelName
+	}
+	return ""
+}
+
+func (m *LabelValuesRequest) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+func (m *LabelValuesRequest) GetStartTimestampMs() int64 {
+	if m != nil {
+		return m.StartTimestampMs
+	}
+	return 0
+}
+
+func (m *LabelValuesRequest) GetEndTimestampMs() int64 {
+	if m != nil {
+		return m.EndTimestampMs
+	}
+	return 0
+}
+
+func (m *LabelValuesRequest) GetLimit() int64 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *LabelValuesRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+type LabelValuesResponse struct {
+	LabelValues []string `protobuf:"bytes,1,rep,name=label_values,json=labelValues" json:"label_values,omitempty"`
+	// Token of the next page, empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken" json:"next_page_token,omitempty"`
+}
+
+func (m *LabelValuesResponse) Reset()                    { *m = LabelValuesResponse{} }
+func (m *LabelValuesResponse) String() string            { return proto.CompactTextString(m) }
+func (*LabelValuesResponse) ProtoMessage()               {}
+func (*LabelValuesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *LabelValuesResponse) GetLabelValues() []string {
+	if m != nil {
+		return m.LabelValues
+	}
+	return nil
+}
+
+func (m *LabelValuesResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+type SeriesRequest struct {
+	// Only series matching all matchers are returned.
+	Matchers []*LabelMatcher `protobuf:"bytes,1,rep,name=matchers" json:"matchers,omitempty"`
+	// Time range to consider, in milliseconds. Zero values leave the
+	// respective end of the range open.
+	StartTimestampMs int64 `protobuf:"varint,2,opt,name=start_timestamp_ms,json=startTimestampMs" json:"start_timestamp_ms,omitempty"`
+	EndTimestampMs   int64 `protobuf:"varint,3,opt,name=end_timestamp_ms,json=endTimestampMs" json:"end_timestamp_ms,omitempty"`
+}
+
+func (m *SeriesRequest) Reset()                    { *m = SeriesRequest{} }
+func (m *SeriesRequest) String() string            { return proto.CompactTextString(m) }
+func (*SeriesRequest) ProtoMessage()               {}
+func (*SeriesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *SeriesRequest) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+func (m *SeriesRequest) GetStartTimestampMs() int64 {
+	if m != nil {
+		return m.StartTimestampMs
+	}
+	return 0
+}
+
+func (m *SeriesRequest) GetEndTimestampMs() int64 {
+	if m != nil {
+		return m.EndTimestampMs
+	}
+	return 0
+}
+
+type SeriesResponse struct {
+	Series []*LabelSet `protobuf:"bytes,1,rep,name=series" json:"series,omitempty"`
+}
+
+func (m *SeriesResponse) Reset()                    { *m = SeriesResponse{} }
+func (m *SeriesResponse) String() string            { return proto.CompactTextString(m) }
+func (*SeriesResponse) ProtoMessage()               {}
+func (*SeriesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *SeriesResponse) GetSeries() []*LabelSet {
+	if m != nil {
+		return m.Series
+	}
+	return nil
+}
+
+// LabelSet identifies a single series by its labels.
+type LabelSet struct {
+	Labels []*LabelPair `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+}
+
+func (m *LabelSet) Reset()                    { *m = LabelSet{} }
+func (m *LabelSet) String() string            { return proto.CompactTextString(m) }
+func (*LabelSet) ProtoMessage()               {}
+func (*LabelSet) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *LabelSet) GetLabels() []*LabelPair {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type ExemplarsRequest struct {
+	// Only exemplars of series matching all matchers are returned.
+	Matchers []*LabelMatcher `protobuf:"bytes,1,rep,name=matchers" json:"matchers,omitempty"`
+	// Time range to consider, in milliseconds. Zero values leave the
+	// respective end of the range open.
+	StartTimestampMs int64 `protobuf:"varint,2,opt,name=start_timestamp_ms,json=startTimestampMs" json:"start_timestamp_ms,omitempty"`
+	EndTimestampMs   int64 `protobuf:"varint,3,opt,name=end_timestamp_ms,json=endTimestampMs" json:"end_timestamp_ms,omitempty"`
+}
+
+func (m *ExemplarsRequest) Reset()                    { *m = ExemplarsRequest{} }
+func (m *ExemplarsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExemplarsRequest) ProtoMessage()               {}
+func (*ExemplarsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *ExemplarsRequest) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+func (m *ExemplarsRequest) GetStartTimestampMs() int64 {
+	if m != nil {
+		return m.StartTimestampMs
+	}
+	return 0
+}
+
+func (m *ExemplarsRequest) GetEndTimestampMs() int64 {
+	if m != nil {
+		return m.EndTimestampMs
+	}
+	return 0
+}
+
+type ExemplarsResponse struct {
+	Exemplars []*Exemplar `protobuf:"bytes,1,rep,name=exemplars" json:"exemplars,omitempty"`
+}
+
+func (m *ExemplarsResponse) Reset()                    { *m = ExemplarsResponse{} }
+func (m *ExemplarsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ExemplarsResponse) ProtoMessage()               {}
+func (*ExemplarsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *ExemplarsResponse) GetExemplars() []*Exemplar {
+	if m != nil {
+		return m.Exemplars
+	}
+	return nil
+}
+
+// ChunkedReadResponse is a single frame of a streamed read response.
+type ChunkedReadResponse struct {
+	ChunkedSeries []*ChunkedSeries `protobuf:"bytes,1,rep,name=chunked_series,json=chunkedSeries" json:"chunked_series,omitempty"`
+	// Index of the query in the request this frame belongs to.
+	QueryIndex int64 `protobuf:"varint,2,opt,name=query_index,json=queryIndex" json:"query_index,omitempty"`
+}
+
+func (m *ChunkedReadResponse) Reset()                    { *m = ChunkedReadResponse{} }
+func (m *ChunkedReadResponse) String() string            { return proto.CompactTextString(m) }
+func (*ChunkedReadResponse) ProtoMessage()               {}
+func (*ChunkedReadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *ChunkedReadResponse) GetChunkedSeries() []*ChunkedSeries {
+	if m != nil {
+		return m.ChunkedSeries
+	}
+	return nil
+}
+
+func (m *ChunkedReadResponse) GetQueryIndex() int64 {
+	if m != nil {
+		return m.QueryIndex
+	}
+	return 0
+}
+
+type ChunkedSeries struct {
+	Labels []*LabelPair `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+	// Sorted by time, oldest chunk first.
+	Chunks []*Chunk `protobuf:"bytes,2,rep,name=chunks" json:"chunks,omitempty"`
+}
+
+func (m *ChunkedSeries) Reset()                    { *m = ChunkedSeries{} }
+func (m *ChunkedSeries) String() string            { return proto.CompactTextString(m) }
+func (*ChunkedSeries) ProtoMessage()               {}
+func (*ChunkedSeries) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *ChunkedSeries) GetLabels() []*LabelPair {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *ChunkedSeries) GetChunks() []*Chunk {
+	if m != nil {
+		return m.Chunks
+	}
+	return nil
+}
+
+type Chunk struct {
+	MinTimeMs int64          `protobuf:"varint,1,opt,name=min_time_ms,json=minTimeMs" json:"min_time_ms,omitempty"`
+	MaxTimeMs int64          `protobuf:"varint,2,opt,name=max_time_ms,json=maxTimeMs" json:"max_time_ms,omitempty"`
+	Type      Chunk_Encoding `protobuf:"varint,3,opt,name=type,enum=remote.Chunk_Encoding" json:"type,omitempty"`
+	Data      []byte         `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *Chunk) Reset()                    { *m = Chunk{} }
+func (m *Chunk) String() string            { return proto.CompactTextString(m) }
+func (*Chunk) ProtoMessage()               {}
+func (*Chunk) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *Chunk) GetMinTimeMs() int64 {
+	if m != nil {
+		return m.MinTimeMs
+	}
+	return 0
+}
+
+func (m *Chunk) GetMaxTimeMs() int64 {
+	if m != nil {
+		return m.MaxTimeMs
+	}
+	return 0
+}
+
+func (m *Chunk) GetType() Chunk_Encoding {
+	if m != nil {
+		return m.Type
+	}
+	return Chunk_UNKNOWN
+}
+
+func (m *Chunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Sample)(nil), "remote.Sample")
 	proto.RegisterType((*LabelPair)(nil), "remote.LabelPair")
 	proto.RegisterType((*TimeSeries)(nil), "remote.TimeSeries")
+	proto.RegisterType((*Exemplar)(nil), "remote.Exemplar")
+	proto.RegisterType((*Histogram)(nil), "remote.Histogram")
+	proto.RegisterType((*BucketSpan)(nil), "remote.BucketSpan")
 	proto.RegisterType((*WriteRequest)(nil), "remote.WriteRequest")
+	proto.RegisterType((*WriteRequestV2)(nil), "remote.WriteRequestV2")
+	proto.RegisterType((*TimeSeriesV2)(nil), "remote.TimeSeriesV2")
+	proto.RegisterType((*ExemplarV2)(nil), "remote.ExemplarV2")
+	proto.RegisterType((*MetadataV2)(nil), "remote.MetadataV2")
+	proto.RegisterType((*MetricMetadata)(nil), "remote.MetricMetadata")
 	proto.RegisterType((*ReadRequest)(nil), "remote.ReadRequest")
 	proto.RegisterType((*ReadResponse)(nil), "remote.ReadResponse")
 	proto.RegisterType((*Query)(nil), "remote.Query")
 	proto.RegisterType((*LabelMatcher)(nil), "remote.LabelMatcher")
 	proto.RegisterType((*QueryResult)(nil), "remote.QueryResult")
+	proto.RegisterType((*LabelNamesRequest)(nil), "remote.LabelNamesRequest")
+	proto.RegisterType((*LabelNamesResponse)(nil), "remote.LabelNamesResponse")
+	proto.RegisterType((*LabelValuesRequest)(nil), "remote.LabelValuesRequest")
+	proto.RegisterType((*LabelValuesResponse)(nil), "remote.LabelValuesResponse")
+	proto.RegisterType((*SeriesRequest)(nil), "remote.SeriesRequest")
+	proto.RegisterType((*SeriesResponse)(nil), "remote.SeriesResponse")
+	proto.RegisterType((*LabelSet)(nil), "remote.LabelSet")
+	proto.RegisterType((*ExemplarsRequest)(nil), "remote.ExemplarsRequest")
+	proto.RegisterType((*ExemplarsResponse)(nil), "remote.ExemplarsResponse")
+	proto.RegisterType((*ChunkedReadResponse)(nil), "remote.ChunkedReadResponse")
+	proto.RegisterType((*ChunkedSeries)(nil), "remote.ChunkedSeries")
+	proto.RegisterType((*Chunk)(nil), "remote.Chunk")
 	proto.RegisterEnum("remote.MatchType", MatchType_name, MatchType_value)
+	proto.RegisterEnum("remote.MetricMetadata_MetricType", MetricMetadata_MetricType_name, MetricMetadata_MetricType_value)
+	proto.RegisterEnum("remote.ReadRequest_ResponseType", ReadRequest_ResponseType_name, ReadRequest_ResponseType_value)
+	proto.RegisterEnum("remote.Chunk_Encoding", Chunk_Encoding_name, Chunk_Encoding_value)
 }
 
 func init() { proto.RegisterFile("remote.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 424 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x53, 0xc1, 0x6e, 0xd3, 0x40,
-	0x10, 0x65, 0xe3, 0x26, 0xc1, 0x63, 0x37, 0x84, 0xa1, 0x87, 0x1c, 0xc3, 0x4a, 0x08, 0x83, 0xa0,
-	0x42, 0x45, 0x70, 0xe3, 0x10, 0x50, 0x04, 0x42, 0x4d, 0x4b, 0xb7, 0x46, 0x70, 0xb3, 0xb6, 0x64,
-	0x24, 0x2c, 0x79, 0x13, 0x77, 0x77, 0x8d, 0x94, 0xcf, 0xe0, 0x8f, 0x51, 0x76, 0xb3, 0x8e, 0x23,
-	0xe5, 0xc4, 0x2d, 0x33, 0xef, 0xbd, 0x99, 0x97, 0x7d, 0x63, 0x48, 0x35, 0xa9, 0xb5, 0xa5, 0xf3,
-	0x5a, 0xaf, 0xed, 0x1a, 0x07, 0xbe, 0xe2, 0x33, 0x18, 0xdc, 0x4a, 0x55, 0x57, 0x84, 0x67, 0xd0,
-	0xff, 0x23, 0xab, 0x86, 0x26, 0x6c, 0xca, 0x32, 0x26, 0x7c, 0x81, 0x4f, 0x21, 0xb5, 0xa5, 0x22,
-	0x63, 0xa5, 0xaa, 0x0b, 0x65, 0x26, 0xbd, 0x29, 0xcb, 0x22, 0x91, 0xb4, 0xbd, 0x85, 0xe1, 0xef,
-	0x20, 0xbe, 0x94, 0x77, 0x54, 0x7d, 0x93, 0xa5, 0x46, 0x84, 0x93, 0x95, 0x54, 0x7e, 0x48, 0x2c,
-	0xdc, 0xef, 0xfd, 0xe4, 0x9e, 0x6b, 0xfa, 0x82, 0x4b, 0x80, 0xbc, 0x54, 0x74, 0x4b, 0xba, 0x24,
-	0x83, 0x2f, 0x60, 0x50, 0x6d, 0x87, 0x98, 0x09, 0x9b, 0x46, 0x59, 0x72, 0xf1, 0xf8, 0x7c, 0x67,
-	0xb7, 0x1d, 0x2d, 0x76, 0x04, 0xcc, 0x60, 0x68, 0x9c, 0xe5, 0xad, 0x9b, 0x2d, 0x77, 0x14, 0xb8,
-	0xfe, 0x9f, 0x88, 0x00, 0xf3, 0x8f, 0x90, 0xfe, 0xd0, 0xa5, 0x25, 0x41, 0xf7, 0x0d, 0x19, 0x8b,
-	0x17, 0x00, 0xce, 0xb8, 0x5b, 0xb9, 0x5b, 0x84, 0x41, 0xbc, 0x37, 0x23, 0x3a, 0x2c, 0xfe, 0x1e,
-	0x12, 0x41, 0x72, 0x19, 0x46, 0x3c, 0x87, 0xe1, 0x7d, 0xd3, 0xd5, 0x9f, 0x06, 0xfd, 0x4d, 0x43,
-	0x7a, 0x23, 0x02, 0xca, 0x3f, 0x40, 0xea, 0x75, 0xa6, 0x5e, 0xaf, 0x0c, 0xe1, 0x6b, 0x18, 0x6a,
-	0x32, 0x4d, 0x65, 0x83, 0xf0, 0xc9, 0xa1, 0xd0, 0x61, 0x22, 0x70, 0xf8, 0x5f, 0x06, 0x7d, 0x07,
-	0xe0, 0x2b, 0x40, 0x63, 0xa5, 0xb6, 0xc5, 0x41, 0x0e, 0xcc, 0xe5, 0x30, 0x76, 0x48, 0xbe, 0x0f,
-	0x03, 0x33, 0x18, 0xd3, 0x6a, 0x59, 0x1c, 0xc9, 0x6c, 0x44, 0xab, 0x65, 0x97, 0xf9, 0x06, 0x1e,
-	0x2a, 0x69, 0x7f, 0xfd, 0x26, 0x6d, 0x26, 0x91, 0x73, 0x74, 0x76, 0xf0, 0xe6, 0x0b, 0x0f, 0x8a,
-	0x96, 0xc5, 0x0b, 0x48, 0xbb, 0x08, 0x3e, 0x83, 0x13, 0xbb, 0xa9, 0x7d, 0xd6, 0xa3, 0x7d, 0x62,
-	0x0e, 0xce, 0x37, 0x35, 0x09, 0x07, 0xb7, 0x27, 0xd1, 0x3b, 0x76, 0x12, 0x51, 0xf7, 0x24, 0x66,
-	0x90, 0x74, 0x1e, 0xe3, 0x7f, 0xe2, 0x7a, 0xf9, 0x15, 0xe2, 0x76, 0x3f, 0xc6, 0xd0, 0x9f, 0xdf,
-	0x7c, 0x9f, 0x5d, 0x8e, 0x1f, 0xe0, 0x29, 0xc4, 0x57, 0xd7, 0x79, 0xe1, 0x4b, 0x86, 0x8f, 0x20,
-	0x11, 0xf3, 0xcf, 0xf3, 0x9f, 0xc5, 0x62, 0x96, 0x7f, 0xfa, 0x32, 0xee, 0x21, 0xc2, 0xc8, 0x37,
-	0xae, 0xae, 0x77, 0xbd, 0xe8, 0x6e, 0xe0, 0x3e, 0x95, 0xb7, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff,
-	0x9b, 0x9e, 0x76, 0xb3, 0x3a, 0x03, 0x00, 0x00,
+	// 1462 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x57, 0x4d, 0x6f, 0xdb, 0x46,
+	0x13, 0x0e, 0x45, 0x7d, 0x71, 0xf4, 0x11, 0x7a, 0xed, 0x24, 0x7a, 0xf1, 0xe2, 0x7d, 0xab, 0x10,
+	0x4d, 0xa3, 0x06, 0xad, 0x91, 0xaa, 0xf1, 0xa1, 0x45, 0x7a, 0x50, 0x1d, 0xc5, 0x76, 0x13, 0x49,
+	0xc9, 0x4a, 0x76, 0xdc, 0x13, 0xc3, 0x48, 0x2b, 0x8b, 0x08, 0x49, 0x29, 0xdc, 0x55, 0x60, 0xf7,
+	0x5f, 0xf4, 0xd6, 0x02, 0xbd, 0x17, 0xfd, 0x1f, 0x45, 0x7f, 0x4e, 0x0f, 0xfd, 0x05, 0xc5, 0x7e,
+	0xf1, 0xa3, 0xb1, 0x91, 0xd8, 0xa7, 0xde, 0x38, 0xcf, 0xcc, 0xce, 0x3e, 0x33, 0x3b, 0x33, 0xbb,
+	0x84, 0x7a, 0x4c, 0xc2, 0x25, 0x23, 0xdb, 0xab, 0x78, 0xc9, 0x96, 0xa8, 0x2c, 0x25, 0xa7, 0x07,
+	0xe5, 0xb1, 0x17, 0xae, 0x02, 0x82, 0xb6, 0xa0, 0xf4, 0xd6, 0x0b, 0xd6, 0xa4, 0x65, 0xb4, 0x8d,
+	0x8e, 0x81, 0xa5, 0x80, 0x6e, 0x43, 0x9d, 0xf9, 0x21, 0xa1, 0xcc, 0x0b, 0x57, 0x6e, 0x48, 0x5b,
+	0x85, 0xb6, 0xd1, 0x31, 0x71, 0x2d, 0xc1, 0x06, 0xd4, 0xd9, 0x01, 0xeb, 0xa9, 0xf7, 0x8a, 0x04,
+	0xcf, 0x3c, 0x3f, 0x46, 0x08, 0x8a, 0x91, 0x17, 0x4a, 0x27, 0x16, 0x16, 0xdf, 0xa9, 0xe7, 0x82,
+	0x00, 0xa5, 0xe0, 0xfc, 0x6e, 0x00, 0x4c, 0xfc, 0x90, 0x8c, 0x49, 0xec, 0x13, 0x8a, 0x3e, 0x85,
+	0x72, 0xc0, 0xbd, 0xd0, 0x96, 0xd1, 0x36, 0x3b, 0xb5, 0xee, 0xc6, 0xb6, 0xe2, 0x9b, 0xf8, 0xc6,
+	0xca, 0x00, 0x75, 0xa0, 0x42, 0x05, 0x67, 0x4e, 0x87, 0xdb, 0x36, 0xb5, 0xad, 0x0c, 0x05, 0x6b,
+	0x35, 0xda, 0x06, 0x8b, 0x9c, 0x92, 0x70, 0x15, 0x78, 0x31, 0x6d, 0x99, 0xc2, 0xd6, 0xd6, 0xb6,
+	0x7d, 0xa5, 0xc0, 0xa9, 0x09, 0xfa, 0x02, 0x60, 0xe1, 0x53, 0xb6, 0x3c, 0x89, 0xbd, 0x90, 0xb6,
+	0x8a, 0x79, 0x22, 0xfb, 0x5a, 0x83, 0x33, 0x46, 0x4e, 0x04, 0x55, 0xed, 0xe9, 0x32, 0x31, 0xe4,
+	0x72, 0x72, 0x61, 0xb6, 0xcd, 0x77, 0xb3, 0xfd, 0x6b, 0x11, 0xac, 0x84, 0x09, 0xfa, 0x2f, 0x58,
+	0xd3, 0xe5, 0x3a, 0x62, 0xae, 0x1f, 0x31, 0x91, 0xf3, 0x22, 0xae, 0x0a, 0xe0, 0x20, 0x62, 0xe8,
+	0x23, 0xa8, 0x49, 0xe5, 0x3c, 0x58, 0x7a, 0x4c, 0xed, 0x04, 0x02, 0x7a, 0xcc, 0x11, 0x64, 0x83,
+	0x49, 0xd7, 0xa1, 0xd8, 0xc5, 0xc0, 0xfc, 0x13, 0xdd, 0x84, 0x32, 0x9d, 0x2e, 0x48, 0xe8, 0xb5,
+	0x8a, 0x6d, 0xa3, 0xb3, 0x81, 0x95, 0x84, 0xee, 0x40, 0xf3, 0x07, 0x12, 0x2f, 0x5d, 0xb6, 0x88,
+	0x09, 0x5d, 0x2c, 0x83, 0x59, 0xab, 0x24, 0x16, 0x35, 0x38, 0x3a, 0xd1, 0x20, 0xfa, 0x58, 0x99,
+	0xa5, 0x9c, 0xca, 0x82, 0x53, 0x9d, 0xa3, 0xbb, 0x9a, 0x57, 0x07, 0xec, 0x8c, 0x95, 0x24, 0x57,
+	0x11, 0xee, 0x9a, 0x89, 0x9d, 0x24, 0xf8, 0x15, 0x34, 0x23, 0x72, 0xe2, 0x31, 0xff, 0x2d, 0x71,
+	0xe9, 0xca, 0x8b, 0x68, 0xab, 0x2a, 0x12, 0x8b, 0x74, 0x62, 0xbf, 0x5d, 0x4f, 0x5f, 0x13, 0x36,
+	0x5e, 0x79, 0x11, 0x6e, 0x68, 0x4b, 0x2e, 0x51, 0x74, 0x17, 0xae, 0x27, 0x4b, 0x67, 0x24, 0x60,
+	0x1e, 0x6d, 0x59, 0x6d, 0xb3, 0x83, 0x70, 0xe2, 0xf1, 0x91, 0x40, 0x73, 0x86, 0x82, 0x11, 0x6d,
+	0x41, 0xdb, 0xe4, 0x64, 0x34, 0x2c, 0x08, 0x51, 0x4e, 0x66, 0xb5, 0xa4, 0x7e, 0x86, 0x4c, 0xed,
+	0x62, 0x32, 0xda, 0x32, 0x21, 0x93, 0x2c, 0x55, 0x64, 0xea, 0x92, 0x8c, 0x86, 0x53, 0x32, 0x89,
+	0xa1, 0x22, 0xd3, 0x90, 0x64, 0x34, 0xac, 0xc8, 0xfc, 0xb3, 0x52, 0xae, 0xbf, 0x5b, 0x29, 0x0f,
+	0x01, 0x52, 0x46, 0xfc, 0x64, 0x97, 0xf3, 0x39, 0x25, 0xb2, 0x4c, 0x36, 0xb0, 0x92, 0x38, 0x1e,
+	0x90, 0xe8, 0x84, 0x2d, 0x44, 0x7d, 0x34, 0xb0, 0x92, 0x9c, 0xb7, 0x50, 0x7f, 0x11, 0xfb, 0x8c,
+	0x60, 0xf2, 0x66, 0x4d, 0x28, 0x43, 0x5d, 0x00, 0xe1, 0x5c, 0x74, 0x6b, 0xcb, 0xc8, 0x47, 0x9e,
+	0xf6, 0x31, 0xce, 0x58, 0xa1, 0x2e, 0x54, 0x43, 0xc2, 0xbc, 0x99, 0xc7, 0x3c, 0xd5, 0x7d, 0x37,
+	0xf5, 0x8a, 0x01, 0x61, 0xb1, 0x3f, 0x1d, 0x28, 0x2d, 0x4e, 0xec, 0x9c, 0x97, 0xd0, 0xcc, 0xee,
+	0x7b, 0xd4, 0x45, 0x2d, 0xa8, 0xd0, 0xb3, 0xf0, 0xd5, 0x32, 0x90, 0x1d, 0x69, 0x61, 0x2d, 0xa2,
+	0x07, 0x39, 0x4e, 0x25, 0xb1, 0xc3, 0xd6, 0xbb, 0x9c, 0x8e, 0xba, 0x59, 0x56, 0xce, 0x9f, 0x06,
+	0xd4, 0xb3, 0x4a, 0xde, 0x27, 0xb2, 0x2b, 0xdd, 0x98, 0xcc, 0x65, 0x6c, 0x0d, 0x0c, 0x12, 0xc2,
+	0x64, 0x7e, 0x99, 0x81, 0x93, 0x1f, 0x20, 0xe6, 0x07, 0x0c, 0x10, 0x74, 0x3f, 0x3b, 0xa3, 0x8a,
+	0xf9, 0xbc, 0xea, 0xc9, 0x72, 0xd4, 0xcd, 0x4e, 0xa9, 0xed, 0x4c, 0x5a, 0x79, 0x1b, 0x66, 0x16,
+	0xe8, 0x84, 0x1e, 0x75, 0x33, 0x29, 0x9d, 0x03, 0xa4, 0x8e, 0xde, 0x1f, 0xed, 0x95, 0x47, 0xd3,
+	0x19, 0x40, 0xba, 0x3f, 0xda, 0x81, 0x22, 0x3b, 0x5b, 0xc9, 0x9b, 0xa0, 0xd9, 0xbd, 0x7d, 0xfe,
+	0xc1, 0x2b, 0x71, 0x72, 0xb6, 0x22, 0x58, 0x98, 0xa3, 0xff, 0x40, 0x75, 0x41, 0x82, 0x15, 0x27,
+	0x27, 0xf6, 0x68, 0xe0, 0x0a, 0x97, 0x31, 0x99, 0x73, 0xd5, 0x3a, 0xf2, 0x99, 0x50, 0x15, 0xa5,
+	0x8a, 0xcb, 0x98, 0xcc, 0x9d, 0x9f, 0x0a, 0xd0, 0xcc, 0x7b, 0xbe, 0xea, 0xfe, 0x9f, 0x01, 0x0a,
+	0x05, 0xe6, 0xce, 0xbd, 0xd0, 0x0f, 0xce, 0x5c, 0x71, 0x9d, 0xc9, 0x9b, 0xcb, 0x96, 0x9a, 0xc7,
+	0x42, 0x31, 0xe4, 0x57, 0x1b, 0x82, 0x22, 0x67, 0x27, 0xe8, 0x58, 0x58, 0x7c, 0x73, 0x8c, 0xd3,
+	0x12, 0x47, 0x63, 0x61, 0xf1, 0xad, 0x52, 0xa3, 0x76, 0x42, 0x35, 0xa8, 0x1c, 0x0e, 0x9f, 0x0c,
+	0x47, 0x2f, 0x86, 0xf6, 0x35, 0x2e, 0xec, 0x8e, 0x0e, 0x87, 0x93, 0x3e, 0xb6, 0x0d, 0x64, 0x41,
+	0x69, 0xaf, 0x77, 0xb8, 0xd7, 0xb7, 0x0b, 0xa8, 0x01, 0xd6, 0xfe, 0xc1, 0x78, 0x32, 0xda, 0xc3,
+	0xbd, 0x81, 0x6d, 0x22, 0x04, 0x4d, 0xa1, 0x49, 0xb1, 0x22, 0x5f, 0x3a, 0x3e, 0x1c, 0x0c, 0x7a,
+	0xf8, 0x7b, 0xbb, 0x84, 0xaa, 0x50, 0x3c, 0x18, 0x3e, 0x1e, 0xd9, 0x65, 0x54, 0x87, 0xea, 0x78,
+	0xd2, 0x9b, 0xf4, 0xc7, 0xfd, 0x89, 0x5d, 0x71, 0xfe, 0x30, 0xa0, 0x86, 0x89, 0x37, 0xd3, 0x8d,
+	0x7c, 0x17, 0x2a, 0x6f, 0xd6, 0xd9, 0x2e, 0x6e, 0xe8, 0xd4, 0x3c, 0x5f, 0x93, 0xf8, 0x0c, 0x6b,
+	0x2d, 0x3a, 0x86, 0x5b, 0xde, 0x74, 0x4a, 0x56, 0x8c, 0xcc, 0xdc, 0x98, 0xd0, 0xd5, 0x32, 0xa2,
+	0xc4, 0xe5, 0x39, 0x92, 0x5d, 0xd0, 0xec, 0xb6, 0xf5, 0xc2, 0x8c, 0xfb, 0x6d, 0xac, 0x2c, 0x45,
+	0x4a, 0x6f, 0x68, 0x07, 0x59, 0x94, 0x3a, 0x0f, 0xa0, 0x9e, 0x05, 0x44, 0x1c, 0xbd, 0xc1, 0xb3,
+	0xa7, 0xfd, 0xb1, 0x7d, 0x0d, 0xdd, 0x82, 0xcd, 0xf1, 0x04, 0xf7, 0x7b, 0x83, 0xfe, 0x23, 0xf7,
+	0x78, 0x84, 0xdd, 0xdd, 0xfd, 0xc3, 0xe1, 0x93, 0xb1, 0x6d, 0x38, 0xdf, 0x40, 0x5d, 0x6e, 0x24,
+	0x57, 0xa2, 0xcf, 0xa1, 0x12, 0x13, 0xba, 0x0e, 0x98, 0x0e, 0x64, 0x33, 0x1f, 0x88, 0xd0, 0x61,
+	0x6d, 0xe3, 0xfc, 0x68, 0x40, 0x49, 0x28, 0xf8, 0x11, 0x53, 0xe6, 0xc5, 0xcc, 0xcd, 0x15, 0xb4,
+	0x21, 0x0a, 0xda, 0x16, 0x9a, 0x49, 0x5a, 0xd5, 0xfc, 0xb6, 0x22, 0xd1, 0xcc, 0x3d, 0xe7, 0x15,
+	0xd4, 0x24, 0xd1, 0x2c, 0x6b, 0x79, 0x1f, 0xaa, 0xa1, 0xc7, 0xa6, 0x0b, 0x92, 0x3c, 0x36, 0xb6,
+	0x72, 0x0f, 0x80, 0x81, 0x54, 0xe2, 0xc4, 0xca, 0x71, 0xa1, 0x9e, 0xd5, 0xa0, 0x3b, 0xb9, 0x9a,
+	0x4d, 0x06, 0x87, 0x50, 0x67, 0x6a, 0x54, 0x3f, 0xb2, 0x0a, 0xe7, 0x3d, 0xb2, 0xcc, 0xec, 0x23,
+	0xab, 0x07, 0xb5, 0x4c, 0x32, 0xae, 0x32, 0xc4, 0x9d, 0x3e, 0x6c, 0x08, 0x8e, 0xbc, 0xde, 0xa9,
+	0x2e, 0xa2, 0x6c, 0xa8, 0xc6, 0x07, 0x85, 0xba, 0x03, 0x28, 0xeb, 0x46, 0x9d, 0xa1, 0x1e, 0x46,
+	0xa2, 0xcb, 0xa4, 0x2b, 0x4b, 0x0d, 0x23, 0x61, 0xe8, 0xfc, 0x65, 0xa8, 0x75, 0x47, 0x3c, 0x9e,
+	0x64, 0xff, 0xff, 0x01, 0xa4, 0xeb, 0xd4, 0x63, 0xd3, 0x4a, 0x96, 0xe5, 0xe8, 0x15, 0x3e, 0x84,
+	0xde, 0x05, 0x35, 0x61, 0x5e, 0xa2, 0x26, 0x8a, 0xe7, 0xd6, 0xc4, 0x16, 0x94, 0x02, 0x3f, 0x54,
+	0xd3, 0xc0, 0xc4, 0x52, 0xe0, 0xf4, 0x57, 0xde, 0x09, 0x71, 0xd9, 0xf2, 0x35, 0x89, 0xc4, 0x1b,
+	0xc9, 0xc2, 0x16, 0x47, 0x26, 0x1c, 0x70, 0x5e, 0xc2, 0x66, 0x2e, 0x66, 0x95, 0xac, 0xdb, 0x50,
+	0x97, 0x41, 0x8b, 0xb3, 0xd5, 0xd9, 0xaa, 0x05, 0xa9, 0x29, 0xfa, 0x84, 0x3f, 0x66, 0x4e, 0x99,
+	0x9b, 0xf1, 0x2e, 0x8b, 0xa4, 0xc1, 0xe1, 0x67, 0xc9, 0x0e, 0x3f, 0x1b, 0xd0, 0x50, 0x67, 0x7d,
+	0xd5, 0x13, 0xbd, 0x20, 0x65, 0x85, 0x4b, 0xa4, 0xcc, 0x3c, 0x2f, 0x65, 0xce, 0xd7, 0xd0, 0xd4,
+	0xd4, 0x54, 0xe0, 0x1d, 0x28, 0xe7, 0x4a, 0xd6, 0xce, 0x31, 0x1b, 0x13, 0x86, 0x95, 0xde, 0xd9,
+	0x81, 0xaa, 0xc6, 0x2e, 0xf1, 0x1a, 0x77, 0x7e, 0x31, 0xc0, 0xd6, 0x57, 0xe4, 0xbf, 0x30, 0x23,
+	0xbb, 0xb0, 0x91, 0x61, 0xa7, 0x92, 0x92, 0xfb, 0xb7, 0x31, 0xde, 0xfb, 0x6f, 0xe3, 0x30, 0xd8,
+	0xdc, 0x5d, 0xac, 0xa3, 0xd7, 0x64, 0x96, 0x9b, 0xa2, 0x0f, 0xa1, 0x39, 0x95, 0xb0, 0x9b, 0xcb,
+	0xf1, 0x0d, 0xed, 0x4b, 0x2d, 0x52, 0x47, 0xd2, 0x98, 0x66, 0x45, 0xde, 0xbf, 0xfc, 0xba, 0x38,
+	0x73, 0xfd, 0x68, 0x46, 0x4e, 0x55, 0xa8, 0x20, 0xa0, 0x03, 0x8e, 0x38, 0x1e, 0x34, 0x72, 0x0e,
+	0x2e, 0xf3, 0x8f, 0x74, 0x07, 0xca, 0x62, 0x37, 0xdd, 0xc3, 0x8d, 0x1c, 0x25, 0xac, 0x94, 0xce,
+	0x6f, 0x06, 0x94, 0x04, 0x82, 0xfe, 0x0f, 0xb5, 0xd0, 0x8f, 0x44, 0x46, 0xd3, 0x89, 0x6e, 0x85,
+	0x7e, 0xc4, 0x93, 0x39, 0xa0, 0x42, 0xef, 0x9d, 0x26, 0xfa, 0x82, 0xd2, 0x7b, 0xa7, 0x4a, 0x7f,
+	0x4f, 0x8d, 0x5f, 0x53, 0x8c, 0xdf, 0x9b, 0xb9, 0xed, 0xb6, 0xfb, 0xd1, 0x74, 0x39, 0xf3, 0xa3,
+	0x93, 0x74, 0x06, 0x8b, 0x07, 0x18, 0x6f, 0xfb, 0x3a, 0x16, 0xdf, 0x4e, 0x1b, 0xaa, 0xda, 0x2a,
+	0x7f, 0xc7, 0x57, 0xc0, 0x3c, 0x1e, 0x61, 0xdb, 0xb8, 0xf7, 0x1d, 0x58, 0xc9, 0x30, 0xe7, 0x97,
+	0x7d, 0xff, 0xf9, 0x61, 0xef, 0xa9, 0x7d, 0x8d, 0x5f, 0xf6, 0xc3, 0xd1, 0xc4, 0x95, 0xa2, 0x81,
+	0xae, 0x43, 0x0d, 0xf7, 0xf7, 0xfa, 0xc7, 0xee, 0xa0, 0x37, 0xd9, 0xdd, 0xb7, 0x0b, 0xfc, 0xf6,
+	0x97, 0xc0, 0x70, 0xa4, 0x30, 0xf3, 0x55, 0x59, 0xfc, 0xc9, 0x7f, 0xf9, 0xf7, 0x00, 0x0e, 0xab,
+	0x26, 0x0d, 0xd9, 0x0f, 0x00, 0x00,
 }
@@ -0,0 +1,141 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Field numbers of the descriptor messages the test walks.
+const (
+	fileMessageTypeField   = 4 // FileDescriptorProto.message_type
+	fileEnumTypeField      = 5 // FileDescriptorProto.enum_type
+	nameField              = 1 // DescriptorProto.name, EnumDescriptorProto.name
+	messageNestedTypeField = 3 // DescriptorProto.nested_type
+	messageEnumTypeField   = 4 // DescriptorProto.enum_type
+)
+
+// messageFields returns the length-delimited fields of a protobuf message
+// with the given field number, in order.
+func messageFields(t *testing.T, msg []byte, field uint64) [][]byte {
+	var fields [][]byte
+	b := proto.NewBuffer(msg)
+	for {
+		key, err := b.DecodeVarint()
+		if err != nil {
+			// The end of the message.
+			return fields
+		}
+		switch key & 7 {
+		case 0:
+			_, err = b.DecodeVarint()
+		case 1:
+			_, err = b.DecodeFixed64()
+		case 5:
+			_, err = b.DecodeFixed32()
+		case 2:
+			var data []byte
+			data, err = b.DecodeRawBytes(false)
+			if key>>3 == field {
+				fields = append(fields, data)
+			}
+		default:
+			t.Fatalf("Unexpected wire type %d in descriptor", key&7)
+		}
+		if err != nil {
+			t.Fatalf("Error decoding descriptor: %v", err)
+		}
+	}
+}
+
+// descriptorName returns the names along a Descriptor or EnumDescriptor
+// path into the gzipped file descriptor, joined like Go type names.
+func descriptorName(t *testing.T, gz []byte, path []int, enum bool) string {
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for i, index := range path {
+		field := uint64(fileMessageTypeField)
+		switch {
+		case i > 0 && enum && i == len(path)-1:
+			field = messageEnumTypeField
+		case i > 0:
+			field = messageNestedTypeField
+		case enum && len(path) == 1:
+			field = fileEnumTypeField
+		}
+		fields := messageFields(t, desc, field)
+		if index >= len(fields) {
+			t.Fatalf("Descriptor path %v out of range: %d of %d", path, index, len(fields))
+		}
+		desc = fields[index]
+		name := messageFields(t, desc, nameField)
+		if len(name) != 1 {
+			t.Fatalf("Descriptor at path %v has no name", path)
+		}
+		names = append(names, string(name[0]))
+	}
+	return strings.Join(names, "_")
+}
+
+func TestDescriptorPaths(t *testing.T) {
+	messages := []interface {
+		proto.Message
+		Descriptor() ([]byte, []int)
+	}{
+		&Sample{}, &LabelPair{}, &TimeSeries{}, &Exemplar{}, &Histogram{},
+		&BucketSpan{}, &WriteRequest{}, &WriteRequestV2{}, &TimeSeriesV2{},
+		&ExemplarV2{}, &MetadataV2{}, &MetricMetadata{}, &ReadRequest{},
+		&ReadResponse{}, &Query{}, &LabelMatcher{}, &QueryResult{},
+		&LabelNamesRequest{}, &LabelNamesResponse{}, &LabelValuesRequest{},
+		&LabelValuesResponse{}, &SeriesRequest{}, &SeriesResponse{},
+		&LabelSet{}, &ExemplarsRequest{}, &ExemplarsResponse{},
+		&ChunkedReadResponse{}, &ChunkedSeries{}, &Chunk{},
+	}
+	for _, m := range messages {
+		gz, path := m.Descriptor()
+		want := strings.TrimPrefix(proto.MessageName(m), "remote.")
+		if got := descriptorName(t, gz, path, false); got != want {
+			t.Fatalf("Descriptor path %v of %s names %s", path, want, got)
+		}
+	}
+
+	enums := map[string]interface {
+		EnumDescriptor() ([]byte, []int)
+	}{
+		"MatchType":                 MatchType(0),
+		"MetricMetadata_MetricType": MetricMetadata_MetricType(0),
+		"ReadRequest_ResponseType":  ReadRequest_ResponseType(0),
+		"Chunk_Encoding":            Chunk_Encoding(0),
+	}
+	for want, e := range enums {
+		gz, path := e.EnumDescriptor()
+		if got := descriptorName(t, gz, path, true); got != want {
+			t.Fatalf("Enum descriptor path %v of %s names %s", path, want, got)
+		}
+	}
+}
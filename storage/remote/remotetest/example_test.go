@@ -0,0 +1,56 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotetest_test
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/storage/remote/remotetest"
+)
+
+// sendUp is the code under test, depending on a remote.WriteClient only.
+func sendUp(w remote.WriteClient, value float64) error {
+	return w.Store(context.Background(), &remote.WriteRequest{
+		Timeseries: []*remote.TimeSeries{
+			{
+				Labels:  []*remote.LabelPair{{Name: "__name__", Value: "up"}},
+				Samples: []*remote.Sample{{Value: value, TimestampMs: 1000}},
+			},
+		},
+	})
+}
+
+func ExampleFakeClient() {
+	c := &remotetest.FakeClient{}
+	if err := sendUp(c, 1); err != nil {
+		fmt.Println("unexpected error:", err)
+	}
+
+	// Make the next calls fail.
+	c.StoreErr = errors.New("remote storage unavailable")
+	fmt.Println(sendUp(c, 0))
+
+	for _, req := range c.WriteRequests() {
+		ts := req.Timeseries[0]
+		fmt.Println(ts.Labels[0].Value, ts.Samples[0].Value)
+	}
+	// Output:
+	// remote storage unavailable
+	// up 1
+	// up 0
+}
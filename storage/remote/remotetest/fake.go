@@ -0,0 +1,110 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remotetest provides a fake remote storage client for tests.
+package remotetest
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/storage/remote"
+)
+
+// FakeClient is a remote.WriteClient and remote.ReadClient recording all
+// calls made to it and responding with the results and errors it is
+// programmed with. It is safe for concurrent use, but must not be programmed
+// concurrently.
+type FakeClient struct {
+	// Responses returned by the respective methods.
+	StoreErr          error
+	ReadResult        *remote.QueryResult
+	ReadErr           error
+	LabelValuesResult []string
+	LabelValuesErr    error
+
+	mtx              sync.Mutex
+	writeRequests    []*remote.WriteRequest
+	queries          []*remote.Query
+	labelValuesCalls []LabelValuesCall
+}
+
+// LabelValuesCall holds the arguments of a call to LabelValues.
+type LabelValuesCall struct {
+	Name           string
+	Matchers       []*remote.LabelMatcher
+	StartMs, EndMs int64
+}
+
+var (
+	_ remote.WriteClient   = (*FakeClient)(nil)
+	_ remote.ReadClient    = (*FakeClient)(nil)
+	_ remote.StorageClient = (*FakeClient)(nil)
+)
+
+// Store records the write request and returns StoreErr.
+func (c *FakeClient) Store(_ context.Context, req *remote.WriteRequest) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.writeRequests = append(c.writeRequests, req)
+	return c.StoreErr
+}
+
+// Read records the query and returns ReadResult and ReadErr.
+func (c *FakeClient) Read(_ context.Context, query *remote.Query) (*remote.QueryResult, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.queries = append(c.queries, query)
+	return c.ReadResult, c.ReadErr
+}
+
+// LabelValues records the call and returns LabelValuesResult and
+// LabelValuesErr.
+func (c *FakeClient) LabelValues(_ context.Context, name string, matchers []*remote.LabelMatcher, startMs, endMs int64) ([]string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.labelValuesCalls = append(c.labelValuesCalls, LabelValuesCall{
+		Name:     name,
+		Matchers: matchers,
+		StartMs:  startMs,
+		EndMs:    endMs,
+	})
+	return c.LabelValuesResult, c.LabelValuesErr
+}
+
+// Name identifies the fake client.
+func (c *FakeClient) Name() string {
+	return "fake"
+}
+
+// WriteRequests returns the write requests passed to Store so far.
+func (c *FakeClient) WriteRequests() []*remote.WriteRequest {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]*remote.WriteRequest(nil), c.writeRequests...)
+}
+
+// Queries returns the queries passed to Read so far.
+func (c *FakeClient) Queries() []*remote.Query {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]*remote.Query(nil), c.queries...)
+}
+
+// LabelValuesCalls returns the calls made to LabelValues so far.
+func (c *FakeClient) LabelValuesCalls() []LabelValuesCall {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]LabelValuesCall(nil), c.labelValuesCalls...)
+}
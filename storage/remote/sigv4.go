@@ -0,0 +1,117 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	signer "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// sigV4Service is the AWS service name used when signing requests for
+// Amazon Managed Service for Prometheus.
+const sigV4Service = "aps"
+
+// SigV4Config configures signing of requests with AWS Signature Version 4.
+type SigV4Config struct {
+	// Region to sign for. If empty, it is taken from the AWS environment or
+	// shared configuration.
+	Region string
+	// Static credentials. If both are empty, the default AWS credential
+	// chain is used.
+	AccessKey string
+	SecretKey string
+	// Named profile of the AWS shared configuration to use.
+	Profile string
+	// If set, credentials are obtained by assuming this role.
+	RoleARN string
+}
+
+// sigV4RoundTripper signs every request with AWS Signature Version 4 before
+// handing it to the next http.RoundTripper. As the signature is time-bound,
+// signing happens per round trip, so retried requests are signed afresh.
+type sigV4RoundTripper struct {
+	region string
+	signer *signer.Signer
+	next   http.RoundTripper
+}
+
+func newSigV4RoundTripper(cfg *SigV4Config, next http.RoundTripper) (http.RoundTripper, error) {
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" || cfg.SecretKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region:      aws.String(cfg.Region),
+			Credentials: creds,
+		},
+		Profile: cfg.Profile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create aws session: %s", err)
+	}
+
+	region := cfg.Region
+	if region == "" && sess.Config.Region != nil {
+		region = *sess.Config.Region
+	}
+	if region == "" {
+		return nil, fmt.Errorf("no region configured for SigV4 signing")
+	}
+
+	creds = sess.Config.Credentials
+	if cfg.RoleARN != "" {
+		creds = stscreds.NewCredentials(sess, cfg.RoleARN)
+	}
+
+	return &sigV4RoundTripper{
+		region: region,
+		signer: signer.NewSigner(creds),
+		next:   next,
+	}, nil
+}
+
+func (rt *sigV4RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Sign a copy, as a RoundTripper must not modify the original request.
+	signed := new(http.Request)
+	*signed = *req
+	signed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		signed.Header[k] = v
+	}
+
+	if _, err := rt.signer.Sign(signed, bytes.NewReader(body), sigV4Service, rt.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("error signing request: %s", err)
+	}
+	return rt.next.RoundTrip(signed)
+}
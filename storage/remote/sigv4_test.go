@@ -0,0 +1,98 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestClientSigV4(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		headers []http.Header
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := DecodeWriteRequest(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mtx.Lock()
+			headers = append(headers, r.Header)
+			mtx.Unlock()
+			http.Error(w, "test error", http.StatusServiceUnavailable)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:              &config.URL{URL: serverURL},
+		Timeout:          model.Duration(time.Second),
+		RetryMaxAttempts: 2,
+		SigV4: &SigV4Config{
+			Region:    "us-east-1",
+			AccessKey: "AKIDEXAMPLE",
+			SecretKey: "secret",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Store(context.Background(), testWriteRequest()).(recoverableError); !ok {
+		t.Fatal("Expected recoverable error")
+	}
+
+	// Each attempt must carry its own signature.
+	if len(headers) != 2 {
+		t.Fatalf("Unexpected number of requests; want 2, got %d", len(headers))
+	}
+	for i, h := range headers {
+		if auth := h.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256") {
+			t.Fatalf("%d. Unexpected Authorization header %q", i, auth)
+		}
+		if !strings.Contains(h.Get("Authorization"), "/us-east-1/aps/aws4_request") {
+			t.Fatalf("%d. Unexpected credential scope in %q", i, h.Get("Authorization"))
+		}
+		if h.Get("X-Amz-Date") == "" {
+			t.Fatalf("%d. Missing X-Amz-Date header", i)
+		}
+	}
+}
+
+func TestClientSigV4ConflictingAuth(t *testing.T) {
+	_, err := NewClient(0, &ClientConfig{
+		HTTPClientConfig: config.HTTPClientConfig{BearerToken: "token"},
+		SigV4:            &SigV4Config{Region: "us-east-1"},
+	})
+	if err == nil {
+		t.Fatal("Expected error for conflicting authentication methods")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// StreamedWriteContentType is the Content-Type of streamed write requests,
+// which consist of frames each holding a compressed WriteRequest, and of
+// their responses, which consist of an ack frame for every request frame.
+const StreamedWriteContentType = "application/x-streamed-protobuf; proto=prometheus.WriteRequest"
+
+// Ack frames start with one of these. Error acks are followed by the error
+// message.
+const (
+	streamAckOK    byte = 0
+	streamAckError byte = 1
+)
+
+var (
+	errStreamClosed  = errors.New("write stream closed")
+	errStreamSigning = errors.New("write streams are not supported when signing request bodies with sigv4 or hmac")
+)
+
+// StreamWriter sends write requests as the frames of a single long-running
+// HTTP request, saving the overhead of a request per batch. The server
+// acknowledges every frame, and sends back the error handling it, if any.
+//
+// Streaming is experimental. It requires servers handling the streams with
+// DecodeWriteStream, and write requests are sent as given, without any of
+// the relabeling, filtering, splitting or retrying Store does. As the
+// Content-Encoding is set once for the stream, they are all compressed with
+// the Compression of the client, regardless of CompressionBySize.
+type StreamWriter struct {
+	c      *Client
+	body   *io.PipeWriter
+	frames *ChunkedWriter
+	resp   *http.Response
+	acks   *ChunkedReader
+
+	mtx sync.Mutex
+	err error // Set once the stream is broken or closed.
+}
+
+// OpenStream opens a write stream to the write URL, failing with an
+// HTTPError if the server does not accept it. The stream lasts until it is
+// closed, or the context is done. Streams are not supported with SigV4 or
+// HMAC signing, which need the whole body upfront.
+func (c *Client) OpenStream(ctx context.Context) (*StreamWriter, error) {
+	if err := c.checkOpen(); err != nil {
+		return nil, err
+	}
+	if c.url == nil {
+		return nil, errNoWriteURL
+	}
+	if c.signsBodies {
+		// The round tripper would read the whole body before sending it,
+		// which is never done before the stream is closed.
+		return nil, errStreamSigning
+	}
+
+	pr, pw := io.Pipe()
+	httpReq, err := c.newWriteHTTPRequest(c.url, pr, c.compression, ProtocolVersion1, false)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", StreamedWriteContentType)
+	c.injectSpan(ctx, httpReq)
+
+	// The response headers are sent by the server before reading any frame.
+	httpResp, err := c.do(ctx, c.url, httpReq)
+	if err != nil {
+		pw.CloseWithError(err)
+		return nil, err
+	}
+	if httpResp.StatusCode/100 != 2 {
+		pw.Close()
+		httpErr := c.newHTTPError(httpResp, false)
+		httpResp.Body.Close()
+		return nil, httpErr
+	}
+	return &StreamWriter{
+		c:      c,
+		body:   pw,
+		frames: NewChunkedWriter(pw, nil),
+		resp:   httpResp,
+		acks:   NewChunkedReader(httpResp.Body, DefaultChunkedFrameLimit, nil),
+	}, nil
+}
+
+// Send sends a write request as a frame of the stream, and waits for the
+// server to acknowledge it. Errors handling the request on the server are
+// returned, and leave the stream usable. Any other error breaks the stream,
+// and is returned by all later calls.
+func (s *StreamWriter) Send(req *WriteRequest) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	// Uncompressed empty requests would make empty frames, which cannot be
+	// written.
+	if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
+		return nil
+	}
+
+	bufs := writeBufferPool.Get().(*writeBuffers)
+	defer writeBufferPool.Put(bufs)
+	level := s.c.compressionLevel
+	if s.c.bySize != nil {
+		// The level is meant for the compression of large requests.
+		level = 0
+	}
+	_, compressed, err := encodeWriteRequest(req, s.c.compression, level, bufs)
+	if err != nil {
+		return err
+	}
+	if _, err := s.frames.Write(compressed); err != nil {
+		return s.fail(err)
+	}
+
+	ack, err := s.acks.Next()
+	if err != nil {
+		return s.fail(unexpectedEOF(err))
+	}
+	switch {
+	case len(ack) == 1 && ack[0] == streamAckOK:
+		return nil
+	case len(ack) > 0 && ack[0] == streamAckError:
+		return fmt.Errorf("server failed to handle streamed write request: %s", ack[1:])
+	default:
+		return s.fail(fmt.Errorf("malformed write stream ack %q", ack))
+	}
+}
+
+// fail breaks the stream with the given error. The mutex must be held.
+func (s *StreamWriter) fail(err error) error {
+	s.err = err
+	s.body.CloseWithError(err)
+	s.resp.Body.Close()
+	return err
+}
+
+// Close ends the stream, and waits for the server to finish it. Closing a
+// broken or closed stream is a no-op.
+func (s *StreamWriter) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.err != nil {
+		return nil
+	}
+	s.err = errStreamClosed
+
+	s.body.Close()
+	_, err := io.Copy(ioutil.Discard, s.resp.Body)
+	s.resp.Body.Close()
+	return err
+}
+
+// DecodeWriteStream serves a write stream opened by OpenStream, calling
+// handle with every write request received, and acknowledging it once handle
+// returned. Errors returned by handle, or decoding a request, are sent back
+// to the client, which can go on sending. DecodeWriteStream returns nil once
+// the client closed the stream, or the error that broke it.
+func DecodeWriteStream(w http.ResponseWriter, r *http.Request, handle func(*WriteRequest) error, opts ...DecodeOption) error {
+	o := decodeOptions{maxDecompressedBytes: DefaultMaxDecompressedBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	encoding := r.Header.Get("Content-Encoding")
+	if err := validateEncoding(encoding); err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return &DecodeError{StatusCode: http.StatusUnsupportedMediaType, Err: err}
+	}
+
+	// HTTP/1 servers stop reading the request once responding, unless told
+	// otherwise. HTTP/2 streams are full duplex already, and fail this.
+	http.NewResponseController(w).EnableFullDuplex()
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", StreamedWriteContentType)
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	frames := NewChunkedReader(r.Body, MaxCompressedRequestBytes, nil)
+	acks := NewChunkedWriter(w, flusher)
+	for {
+		compressed, err := frames.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req WriteRequest
+		err = decodeCompressed(encoding, compressed, &req, o)
+		if err == nil {
+			err = handle(&req)
+		}
+		ack := []byte{streamAckOK}
+		if err != nil {
+			ack = append([]byte{streamAckError}, err.Error()...)
+		}
+		if _, err := acks.Write(ack); err != nil {
+			return err
+		}
+	}
+}
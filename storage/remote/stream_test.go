@@ -0,0 +1,191 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestStreamWriter(t *testing.T) {
+	var (
+		requests int32
+		received []*WriteRequest
+		done     = make(chan error, 1)
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			done <- DecodeWriteStream(w, r, func(req *WriteRequest) error {
+				received = append(received, req)
+				if req.Timeseries[0].Labels[0].Value == "bad_metric" {
+					return errors.New("test error")
+				}
+				return nil
+			})
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:     &config.URL{URL: serverURL},
+		Timeout: model.Duration(time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.OpenStream(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error opening stream: %v", err)
+	}
+
+	var want []*WriteRequest
+	for i, name := range []string{"test_metric_0", "bad_metric", "test_metric_1"} {
+		req := toWriteRequest(model.Samples{{
+			Metric: model.Metric{model.MetricNameLabel: model.LabelValue(name)},
+			Value:  model.SampleValue(i),
+		}})
+		err := s.Send(req)
+		if name == "bad_metric" {
+			if err == nil || !strings.Contains(err.Error(), "test error") {
+				t.Fatalf("%d. Expected error from the server, got %v", i, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		want = append(want, req)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Unexpected error closing stream: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error decoding stream: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the stream to end")
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Fatalf("Unexpected write requests; want %v, got %v", want, received)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("Unexpected number of HTTP requests; want 1, got %d", got)
+	}
+
+	if err := s.Send(testWriteRequest()); err != errStreamClosed {
+		t.Fatalf("Expected error sending to closed stream, got %v", err)
+	}
+}
+
+func TestOpenStreamError(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "test error", http.StatusNotFound)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:     &config.URL{URL: serverURL},
+		Timeout: model.Duration(time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.OpenStream(context.Background())
+	httpErr, ok := err.(*HTTPError)
+	if !ok || httpErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected 404 HTTPError, got %v", err)
+	}
+}
+
+func TestOpenStreamSigning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote_stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretFile, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:            &config.URL{URL: serverURL},
+		Timeout:        model.Duration(time.Second),
+		HMACSecretFile: secretFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.OpenStream(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != errStreamSigning {
+			t.Fatalf("Expected signing error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out opening stream")
+	}
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Fatalf("Unexpected number of HTTP requests; want 0, got %d", got)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// TimestampEncodingHeader is set on write requests whose sample timestamps
+// are relative. Servers advertise support for relative timestamps by setting
+// it to RelativeTimestamps on responses to OPTIONS requests.
+const TimestampEncodingHeader = "X-Prometheus-Remote-Write-Timestamp-Encoding"
+
+// RelativeTimestamps is the TimestampEncodingHeader value denoting that the
+// timestamp of every sample but the first of a series is relative to the
+// first one, which keeps them small on the wire.
+const RelativeTimestamps = "relative"
+
+// States of the support for relative timestamps of the write endpoint.
+const (
+	timestampSupportUnknown int32 = iota
+	timestampSupportYes
+	timestampSupportNo
+)
+
+// relativeTimestamps returns a write request with relative timestamps. The
+// given request is left untouched.
+func relativeTimestamps(req *WriteRequest) *WriteRequest {
+	rebased := &WriteRequest{
+		Timeseries: make([]*TimeSeries, 0, len(req.Timeseries)),
+		Metadata:   req.Metadata,
+	}
+	for _, ts := range req.Timeseries {
+		cp := *ts
+		if len(ts.Samples) > 1 {
+			base := ts.Samples[0].TimestampMs
+			cp.Samples = make([]*Sample, 0, len(ts.Samples))
+			cp.Samples = append(cp.Samples, ts.Samples[0])
+			for _, s := range ts.Samples[1:] {
+				cp.Samples = append(cp.Samples, &Sample{Value: s.Value, TimestampMs: s.TimestampMs - base})
+			}
+		}
+		rebased.Timeseries = append(rebased.Timeseries, &cp)
+	}
+	return rebased
+}
+
+// absoluteTimestamps turns the relative timestamps of a decoded write request
+// back into absolute ones, in place.
+func absoluteTimestamps(req *WriteRequest) {
+	for _, ts := range req.Timeseries {
+		if len(ts.Samples) < 2 {
+			continue
+		}
+		base := ts.Samples[0].TimestampMs
+		for _, s := range ts.Samples[1:] {
+			s.TimestampMs += base
+		}
+	}
+}
+
+// supportsRelativeTimestamps reports whether the write endpoint supports
+// relative timestamps. It asks the endpoint with an OPTIONS request until it
+// gets an answer, which is kept for the lifetime of the client.
+func (c *Client) supportsRelativeTimestamps(ctx context.Context) bool {
+	switch atomic.LoadInt32(&c.timestampSupport) {
+	case timestampSupportYes:
+		return true
+	case timestampSupportNo:
+		return false
+	}
+
+	httpReq, err := http.NewRequest("OPTIONS", c.url.String(), nil)
+	if err != nil {
+		return false
+	}
+	httpReq.Header.Set("User-Agent", c.userAgent)
+
+	ctx, cancel := context.WithTimeout(ctx, c.healthTimeout)
+	defer cancel()
+
+	httpResp, err := ctxhttp.Do(ctx, c.client, httpReq)
+	if err != nil {
+		// Ask again next time.
+		return false
+	}
+	drainBody(httpResp.Body, c.maxErrMsgLen)
+	if httpResp.StatusCode/100 == 5 {
+		return false
+	}
+
+	if httpResp.Header.Get(TimestampEncodingHeader) == RelativeTimestamps {
+		atomic.StoreInt32(&c.timestampSupport, timestampSupportYes)
+		return true
+	}
+	atomic.StoreInt32(&c.timestampSupport, timestampSupportNo)
+	return false
+}
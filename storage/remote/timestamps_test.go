@@ -0,0 +1,160 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func testTimestampsRequest() *WriteRequest {
+	return &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{
+				Labels: []*LabelPair{{Name: "__name__", Value: "a"}},
+				Samples: []*Sample{
+					{Value: 1, TimestampMs: 1500000000000},
+					{Value: 2, TimestampMs: 1500000015000},
+					{Value: 3, TimestampMs: 1500000030000},
+				},
+			},
+			{
+				Labels:  []*LabelPair{{Name: "__name__", Value: "b"}},
+				Samples: []*Sample{{Value: 4, TimestampMs: 1500000000000}},
+			},
+		},
+	}
+}
+
+func TestRelativeTimestampsRoundTrip(t *testing.T) {
+	req := testTimestampsRequest()
+
+	rebased := relativeTimestamps(req)
+	wantRelative := []int64{1500000000000, 15000, 30000}
+	for i, s := range rebased.Timeseries[0].Samples {
+		if s.TimestampMs != wantRelative[i] {
+			t.Fatalf("%d. Unexpected relative timestamp; want %d, got %d", i, wantRelative[i], s.TimestampMs)
+		}
+	}
+	if !reflect.DeepEqual(req, testTimestampsRequest()) {
+		t.Fatalf("Original request was modified: %v", req)
+	}
+	if proto.Size(rebased) >= proto.Size(req) {
+		t.Fatalf("Relative timestamps did not shrink the request; %d >= %d bytes", proto.Size(rebased), proto.Size(req))
+	}
+
+	absoluteTimestamps(rebased)
+	if !reflect.DeepEqual(rebased, req) {
+		t.Fatalf("Unexpected request after round trip; want %v, got %v", req, rebased)
+	}
+}
+
+func TestClientOptimizeTimestamps(t *testing.T) {
+	for _, advertise := range []bool{true, false} {
+		var (
+			mtx      sync.Mutex
+			options  int
+			encoding string
+			raw      WriteRequest
+			got      *WriteRequest
+		)
+		server := httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				if r.Method == "OPTIONS" {
+					options++
+					if advertise {
+						w.Header().Set(TimestampEncodingHeader, RelativeTimestamps)
+					}
+					return
+				}
+
+				encoding = r.Header.Get(TimestampEncodingHeader)
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				data, err := decompress(r.Header.Get("Content-Encoding"), body, 0)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if err := proto.Unmarshal(data, &raw); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				if got, err = DecodeWriteRequest(r); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+			}),
+		)
+
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			panic(err)
+		}
+
+		c, err := NewClient(0, &ClientConfig{
+			URL:                &config.URL{URL: serverURL},
+			Timeout:            model.Duration(time.Second),
+			OptimizeTimestamps: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := c.Store(context.Background(), testTimestampsRequest()); err != nil {
+				t.Fatalf("%v: unexpected error: %v", advertise, err)
+			}
+		}
+
+		mtx.Lock()
+		if options != 1 {
+			t.Fatalf("%v: unexpected number of probes; want 1, got %d", advertise, options)
+		}
+		wantEncoding, wantSecond := "", int64(1500000015000)
+		if advertise {
+			wantEncoding, wantSecond = RelativeTimestamps, 15000
+		}
+		if encoding != wantEncoding {
+			t.Fatalf("%v: unexpected timestamp encoding; want %q, got %q", advertise, wantEncoding, encoding)
+		}
+		if ts := raw.Timeseries[0].Samples[1].TimestampMs; ts != wantSecond {
+			t.Fatalf("%v: unexpected timestamp on the wire; want %d, got %d", advertise, wantSecond, ts)
+		}
+		if !reflect.DeepEqual(got, testTimestampsRequest()) {
+			t.Fatalf("%v: unexpected decoded request; want %v, got %v", advertise, testTimestampsRequest(), got)
+		}
+		mtx.Unlock()
+
+		server.Close()
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// Span tags set in addition to the standard ones.
+const (
+	samplesTag = "samples"
+	attemptTag = "attempt"
+)
+
+// startSpan starts a span for a remote storage operation against the given
+// URL, as a child of the span in ctx if there is one. The returned context
+// carries the new span.
+func (c *Client) startSpan(ctx context.Context, operation string, u *config.URL) (opentracing.Span, context.Context) {
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	span := c.tracer.StartSpan("remote_"+operation, opts...)
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPUrl.Set(span, u.String())
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// finishSpan finishes a span, recording the error the operation failed with,
+// if any.
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+	}
+	span.Finish()
+}
+
+// injectSpan propagates the span in ctx, if any, to the server through the
+// headers of the request. The header format is up to the tracer.
+func (c *Client) injectSpan(ctx context.Context, req *http.Request) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		// Failing to propagate the span must not fail the request.
+		c.tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	}
+}
+
+// observeResponse records the status code of a response on the span in ctx,
+// if any.
+func observeResponse(ctx context.Context, resp *http.Response) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	}
+}
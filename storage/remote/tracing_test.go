@@ -0,0 +1,145 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// recordingTracer keeps all finished spans in memory.
+type recordingTracer struct {
+	opentracing.NoopTracer
+
+	mtx      sync.Mutex
+	finished []*recordingSpan
+}
+
+func (t *recordingTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return &recordingSpan{
+		Span:      t.NoopTracer.StartSpan(operationName),
+		tracer:    t,
+		operation: operationName,
+		tags:      map[string]interface{}{},
+	}
+}
+
+func (t *recordingTracer) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	carrier.(opentracing.HTTPHeadersCarrier).Set("Traceparent", "00-test-01")
+	return nil
+}
+
+type recordingSpan struct {
+	opentracing.Span
+	tracer    *recordingTracer
+	operation string
+
+	mtx  sync.Mutex
+	tags map[string]interface{}
+}
+
+func (s *recordingSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tags[key] = value
+	return s
+}
+
+func (s *recordingSpan) Finish() {
+	s.tracer.mtx.Lock()
+	defer s.tracer.mtx.Unlock()
+	s.tracer.finished = append(s.tracer.finished, s)
+}
+
+func TestClientTracing(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("Traceparent")
+			if r.URL.Path == "/fail" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+			}
+		}),
+	)
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus uint16
+		wantError  bool
+	}{
+		{path: "/", wantStatus: 200},
+		{path: "/fail", wantStatus: 400, wantError: true},
+	}
+
+	for i, test := range tests {
+		serverURL, err := url.Parse(server.URL + test.path)
+		if err != nil {
+			panic(err)
+		}
+
+		tracer := &recordingTracer{}
+		c, err := NewClient(0, &ClientConfig{
+			URL:     &config.URL{URL: serverURL},
+			Timeout: model.Duration(time.Second),
+			Tracer:  tracer,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := toWriteRequest(model.Samples{
+			{Metric: model.Metric{model.MetricNameLabel: "a"}, Value: 1},
+			{Metric: model.Metric{model.MetricNameLabel: "b"}, Value: 2},
+		})
+		err = c.Store(context.Background(), req)
+		if (err != nil) != test.wantError {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+
+		if len(tracer.finished) != 1 {
+			t.Fatalf("%d. Unexpected number of spans; want 1, got %d", i, len(tracer.finished))
+		}
+		span := tracer.finished[0]
+		if span.operation != "remote_store" {
+			t.Fatalf("%d. Unexpected operation; want %q, got %q", i, "remote_store", span.operation)
+		}
+		for key, want := range map[string]interface{}{
+			"http.status_code": test.wantStatus,
+			"http.url":         serverURL.String(),
+			"samples":          2,
+			"attempt":          1,
+		} {
+			if got := span.tags[key]; got != want {
+				t.Fatalf("%d. Unexpected %s tag; want %v, got %v", i, key, want, got)
+			}
+		}
+		if got := span.tags["error"] == true; got != test.wantError {
+			t.Fatalf("%d. Unexpected error tag; want %v, got %v", i, test.wantError, span.tags["error"])
+		}
+		if traceparent != "00-test-01" {
+			t.Fatalf("%d. Span not propagated to the server, got traceparent %q", i, traceparent)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// unixPathParam is the query parameter of unix:// URLs holding the HTTP path
+// requests are sent to, "/" if it is not set. The URL path is the path of
+// the socket, as in unix:///run/receiver.sock?path=/api/v1/write.
+const unixPathParam = "path"
+
+// unixRoundTripper sends requests to unix:// URLs over HTTP on the unix
+// socket they name.
+type unixRoundTripper struct {
+	t *http.Transport
+}
+
+// newUnixRoundTripper returns a round tripper for unix:// URLs pooling
+// connections and timing out like the given transport.
+func newUnixRoundTripper(t *http.Transport) *unixRoundTripper {
+	return &unixRoundTripper{t: &http.Transport{
+		DialContext:         dialUnix,
+		MaxIdleConns:        t.MaxIdleConns,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		IdleConnTimeout:     t.IdleConnTimeout,
+		DisableKeepAlives:   t.DisableKeepAlives,
+
+		ResponseHeaderTimeout: t.ResponseHeaderTimeout,
+	}}
+}
+
+// dialUnix dials the unix socket whose path is hex-encoded in the host of
+// addr, as set by RoundTrip, so that connections are pooled by socket.
+func dialUnix(ctx context.Context, _, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	path, err := hex.DecodeString(host)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", string(path))
+}
+
+func (rt *unixRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	path := query.Get(unixPathParam)
+	if path == "" {
+		path = "/"
+	}
+	query.Del(unixPathParam)
+
+	// Modify a copy, as a RoundTripper must not modify the original request.
+	r := new(http.Request)
+	*r = *req
+	r.URL = &url.URL{
+		Scheme:   "http",
+		Host:     hex.EncodeToString([]byte(req.URL.Path)),
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	r.Host = "localhost"
+	return rt.t.RoundTrip(r)
+}
+
+// CloseIdleConnections closes the idle connections to all sockets.
+func (rt *unixRoundTripper) CloseIdleConnections() {
+	rt.t.CloseIdleConnections()
+}
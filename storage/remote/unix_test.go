@@ -0,0 +1,128 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestClientUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote_unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "receiver.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets not supported: %v", err)
+	}
+
+	var (
+		path string
+		got  *WriteRequest
+	)
+	server := httptest.NewUnstartedServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			var err error
+			if got, err = DecodeWriteRequest(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		}),
+	)
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	for _, test := range []struct {
+		url      string
+		wantPath string
+	}{
+		{url: "unix://" + socket, wantPath: "/"},
+		{url: "unix://" + socket + "?path=/api/v1/write", wantPath: "/api/v1/write"},
+	} {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewClient(0, &ClientConfig{
+			URL:     &config.URL{URL: u},
+			Timeout: model.Duration(time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := toWriteRequest(model.Samples{{Metric: model.Metric{model.MetricNameLabel: "a"}, Value: 1}})
+		if err := c.Store(context.Background(), req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.url, err)
+		}
+		if path != test.wantPath {
+			t.Fatalf("%s: unexpected path; want %q, got %q", test.url, test.wantPath, path)
+		}
+		if !reflect.DeepEqual(got, req) {
+			t.Fatalf("%s: unexpected write request; want %v, got %v", test.url, req, got)
+		}
+		c.Close()
+	}
+
+	if _, err := NewClient(0, &ClientConfig{URL: &config.URL{URL: &url.URL{Scheme: "unix"}}}); err == nil {
+		t.Fatal("Expected error for a unix URL without a socket path")
+	}
+}
+
+func TestClientIPv6(t *testing.T) {
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 not supported: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Hostname() != "::1" {
+		t.Fatalf("Unexpected host of %s", u)
+	}
+	c, err := NewClient(0, &ClientConfig{
+		URL:     &config.URL{URL: u},
+		Timeout: model.Duration(time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -16,6 +16,7 @@ package remote
 import (
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 
 	"github.com/prometheus/prometheus/config"
@@ -36,16 +37,19 @@ func (w *Writer) ApplyConfig(conf *config.Config) error {
 	// TODO: we should only stop & recreate queues which have changes,
 	// as this can be quite disruptive.
 	for i, rwConf := range conf.RemoteWriteConfigs {
-		c, err := NewClient(i, &clientConfig{
-			url:              rwConf.URL,
-			timeout:          rwConf.RemoteTimeout,
-			httpClientConfig: rwConf.HTTPClientConfig,
+		c, err := NewClient(i, &ClientConfig{
+			URL:              rwConf.URL,
+			Timeout:          rwConf.RemoteTimeout,
+			HTTPClientConfig: rwConf.HTTPClientConfig,
+			Registerer:       prometheus.DefaultRegisterer,
 		})
 		if err != nil {
 			return err
 		}
+		qmConf := defaultQueueManagerConfig
+		qmConf.Registerer = prometheus.DefaultRegisterer
 		newQueues = append(newQueues, NewQueueManager(
-			defaultQueueManagerConfig,
+			qmConf,
 			conf.GlobalConfig.ExternalLabels,
 			rwConf.WriteRelabelConfigs,
 			c,
@@ -0,0 +1,127 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+)
+
+// Versions of the remote write protocol.
+const (
+	ProtocolVersion1 = "1.0"
+	ProtocolVersion2 = "2.0"
+)
+
+// Values of the X-Prometheus-Remote-Write-Version header and Content-Type of
+// write requests, by protocol version.
+var (
+	writeVersionHeaders = map[string]string{
+		ProtocolVersion1: "0.1.0",
+		ProtocolVersion2: "2.0.0",
+	}
+	writeContentTypes = map[string]string{
+		ProtocolVersion1: "application/x-protobuf",
+		ProtocolVersion2: "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+	}
+)
+
+// validateProtocolVersion returns an error if the given remote write protocol
+// version is not supported. The empty string selects the default, 1.0.
+func validateProtocolVersion(version string) error {
+	switch version {
+	case "", ProtocolVersion1, ProtocolVersion2:
+		return nil
+	default:
+		return fmt.Errorf("unsupported remote write protocol version %q", version)
+	}
+}
+
+// symbolTable interns the strings of a WriteRequestV2.
+type symbolTable struct {
+	symbols []string
+	refs    map[string]uint32
+}
+
+func newSymbolTable() *symbolTable {
+	// The empty string is always the first symbol.
+	return &symbolTable{
+		symbols: []string{""},
+		refs:    map[string]uint32{"": 0},
+	}
+}
+
+func (t *symbolTable) ref(s string) uint32 {
+	if ref, ok := t.refs[s]; ok {
+		return ref
+	}
+	ref := uint32(len(t.symbols))
+	t.symbols = append(t.symbols, s)
+	t.refs[s] = ref
+	return ref
+}
+
+func (t *symbolTable) labelsRefs(labels []*LabelPair) []uint32 {
+	refs := make([]uint32, 0, 2*len(labels))
+	for _, l := range labels {
+		refs = append(refs, t.ref(l.Name), t.ref(l.Value))
+	}
+	return refs
+}
+
+// toWriteRequestV2 converts a write request to the remote write 2.0 format.
+// As metadata is sent along with series there, metadata is attached to the
+// series of the metric family it describes, and dropped if there are none.
+func toWriteRequestV2(req *WriteRequest) *WriteRequestV2 {
+	metadata := make(map[string]*MetricMetadata, len(req.Metadata))
+	for _, md := range req.Metadata {
+		metadata[md.MetricFamilyName] = md
+	}
+
+	symbols := newSymbolTable()
+	v2 := &WriteRequestV2{
+		Timeseries: make([]*TimeSeriesV2, 0, len(req.Timeseries)),
+	}
+	for _, ts := range req.Timeseries {
+		tsV2 := &TimeSeriesV2{
+			LabelsRefs: symbols.labelsRefs(ts.Labels),
+			Samples:    ts.Samples,
+			Histograms: ts.Histograms,
+		}
+		for _, e := range ts.Exemplars {
+			tsV2.Exemplars = append(tsV2.Exemplars, &ExemplarV2{
+				LabelsRefs:  symbols.labelsRefs(e.Labels),
+				Value:       e.Value,
+				TimestampMs: e.TimestampMs,
+			})
+		}
+		for _, l := range ts.Labels {
+			if l.Name != model.MetricNameLabel {
+				continue
+			}
+			if md, ok := metadata[l.Value]; ok {
+				tsV2.Metadata = &MetadataV2{
+					Type:    md.Type,
+					HelpRef: symbols.ref(md.Help),
+					UnitRef: symbols.ref(md.Unit),
+				}
+			}
+			break
+		}
+		v2.Timeseries = append(v2.Timeseries, tsV2)
+	}
+	v2.Symbols = symbols.symbols
+	return v2
+}
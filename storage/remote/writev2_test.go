@@ -0,0 +1,267 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestToWriteRequestV2(t *testing.T) {
+	req := &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{
+				Labels:    []*LabelPair{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}},
+				Samples:   []*Sample{{Value: 1, TimestampMs: 1}},
+				Exemplars: []*Exemplar{{Labels: []*LabelPair{{Name: "trace_id", Value: "a"}}, Value: 1, TimestampMs: 1}},
+			},
+			{
+				Labels:  []*LabelPair{{Name: "__name__", Value: "down"}, {Name: "job", Value: "a"}},
+				Samples: []*Sample{{Value: 0, TimestampMs: 2}},
+			},
+		},
+		Metadata: []*MetricMetadata{
+			{Type: MetricMetadata_GAUGE, MetricFamilyName: "up", Help: "Whether the target is up."},
+		},
+	}
+
+	want := &WriteRequestV2{
+		Symbols: []string{"", "__name__", "up", "job", "a", "trace_id", "Whether the target is up.", "down"},
+		Timeseries: []*TimeSeriesV2{
+			{
+				LabelsRefs: []uint32{1, 2, 3, 4},
+				Samples:    []*Sample{{Value: 1, TimestampMs: 1}},
+				Exemplars:  []*ExemplarV2{{LabelsRefs: []uint32{5, 4}, Value: 1, TimestampMs: 1}},
+				Metadata:   &MetadataV2{Type: MetricMetadata_GAUGE, HelpRef: 6, UnitRef: 0},
+			},
+			{
+				LabelsRefs: []uint32{1, 7, 3, 4},
+				Samples:    []*Sample{{Value: 0, TimestampMs: 2}},
+			},
+		},
+	}
+	if got := toWriteRequestV2(req); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unexpected write request; want %v, got %v", want, got)
+	}
+}
+
+func TestClientProtocolVersion(t *testing.T) {
+	tests := []struct {
+		version     string
+		wantHeader  string
+		wantContent string
+	}{
+		{
+			version:     "",
+			wantHeader:  "0.1.0",
+			wantContent: "application/x-protobuf",
+		},
+		{
+			version:     ProtocolVersion1,
+			wantHeader:  "0.1.0",
+			wantContent: "application/x-protobuf",
+		},
+		{
+			version:     ProtocolVersion2,
+			wantHeader:  "2.0.0",
+			wantContent: "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+		},
+	}
+
+	req := &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{
+				Labels:  []*LabelPair{{Name: "__name__", Value: "up"}},
+				Samples: []*Sample{{Value: 1, TimestampMs: 1}},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		var (
+			header  http.Header
+			decoded interface{}
+		)
+		server := httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header
+				var err error
+				if r.Header.Get("X-Prometheus-Remote-Write-Version") == "2.0.0" {
+					decoded, err = DecodeWriteRequestV2(r)
+				} else {
+					decoded, err = DecodeWriteRequest(r)
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				}
+			}),
+		)
+
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			panic(err)
+		}
+
+		c, err := NewClient(0, &ClientConfig{
+			URL:             &config.URL{URL: serverURL},
+			Timeout:         model.Duration(time.Second),
+			ProtocolVersion: test.version,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Store(context.Background(), req); err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		if got := header.Get("X-Prometheus-Remote-Write-Version"); got != test.wantHeader {
+			t.Fatalf("%d. Unexpected version header; want %q, got %q", i, test.wantHeader, got)
+		}
+		if got := header.Get("Content-Type"); got != test.wantContent {
+			t.Fatalf("%d. Unexpected Content-Type; want %q, got %q", i, test.wantContent, got)
+		}
+		var want interface{} = req
+		if test.version == ProtocolVersion2 {
+			want = toWriteRequestV2(req)
+		}
+		if !reflect.DeepEqual(decoded, want) {
+			t.Fatalf("%d. Unexpected write request; want %v, got %v", i, want, decoded)
+		}
+
+		server.Close()
+	}
+
+	u := &config.URL{URL: &url.URL{Scheme: "http", Host: "localhost:9201"}}
+	if _, err := NewClient(0, &ClientConfig{URL: u, ProtocolVersion: "3.0"}); err == nil {
+		t.Fatal("Expected error for unsupported protocol version")
+	}
+}
+
+func TestClientProtocolVersionFallback(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		versions []string
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := r.Header.Get("X-Prometheus-Remote-Write-Version")
+			mtx.Lock()
+			versions = append(versions, version)
+			mtx.Unlock()
+			if version != "0.1.0" {
+				http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+			}
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:             &config.URL{URL: serverURL},
+		Timeout:         model.Duration(time.Second),
+		ProtocolVersion: ProtocolVersion2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Store(context.Background(), testWriteRequest()); err != nil {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+	}
+
+	// Once downgraded, the client sticks to 1.0.
+	want := []string{"2.0.0", "0.1.0", "0.1.0"}
+	mtx.Lock()
+	defer mtx.Unlock()
+	if !reflect.DeepEqual(versions, want) {
+		t.Fatalf("Unexpected version headers; want %v, got %v", want, versions)
+	}
+}
+
+func TestClientServerWriteVersion(t *testing.T) {
+	var (
+		mtx    sync.Mutex
+		code   = http.StatusNoContent
+		header = "2.0.0"
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if header != "" {
+				w.Header().Set("X-Prometheus-Remote-Write-Version", header)
+			}
+			w.WriteHeader(code)
+		}),
+	)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	c, err := NewClient(0, &ClientConfig{
+		URL:             &config.URL{URL: serverURL},
+		Timeout:         model.Duration(time.Second),
+		ProtocolVersion: ProtocolVersion2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ServerWriteVersion(); got != "" {
+		t.Fatalf("Unexpected version before any write: %q", got)
+	}
+
+	tests := []struct {
+		code   int
+		header string
+		want   string
+	}{
+		{code: http.StatusNoContent, header: "2.0.0", want: "2.0.0"},
+		{code: http.StatusOK, header: "0.1.0", want: "0.1.0"},
+		// Failed writes leave the version as it was.
+		{code: http.StatusBadRequest, header: "2.0.0", want: "0.1.0"},
+		{code: http.StatusNoContent, want: ""},
+	}
+	for i, test := range tests {
+		mtx.Lock()
+		code, header = test.code, test.header
+		mtx.Unlock()
+
+		err := c.Store(context.Background(), testWriteRequest())
+		if (err != nil) != (test.code/100 != 2) {
+			t.Fatalf("%d. Unexpected error: %v", i, err)
+		}
+		if got := c.ServerWriteVersion(); got != test.want {
+			t.Fatalf("%d. Unexpected server write version; want %q, got %q", i, test.want, got)
+		}
+	}
+}
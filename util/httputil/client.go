@@ -21,7 +21,9 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/prometheus/config"
@@ -32,38 +34,46 @@ func NewClient(rt http.RoundTripper) *http.Client {
 	return &http.Client{Transport: rt}
 }
 
+// TransportOption modifies the http.Transport underlying a client created by
+// NewClientFromConfig.
+type TransportOption func(*http.Transport)
+
 // NewClientFromConfig returns a new HTTP client configured for the
-// given config.HTTPClientConfig.
-func NewClientFromConfig(cfg config.HTTPClientConfig) (*http.Client, error) {
+// given config.HTTPClientConfig. The options are applied to the transport
+// in order.
+func NewClientFromConfig(cfg config.HTTPClientConfig, opts ...TransportOption) (*http.Client, error) {
 	tlsConfig, err := NewTLSConfig(cfg.TLSConfig)
 	if err != nil {
 		return nil, err
 	}
 	// The only timeout we care about is the configured scrape timeout.
 	// It is applied on request. So we leave out any timings here.
-	var rt http.RoundTripper = &http.Transport{
+	transport := &http.Transport{
 		Proxy:             http.ProxyURL(cfg.ProxyURL.URL),
 		DisableKeepAlives: true,
 		TLSClientConfig:   tlsConfig,
 	}
+	for _, opt := range opts {
+		opt(transport)
+	}
+	var rt http.RoundTripper = transport
 
 	// If a bearer token is provided, create a round tripper that will set the
 	// Authorization header correctly on each request.
-	bearerToken := cfg.BearerToken
-	if len(bearerToken) == 0 && len(cfg.BearerTokenFile) > 0 {
-		b, err := ioutil.ReadFile(cfg.BearerTokenFile)
+	if len(cfg.BearerToken) > 0 {
+		rt = NewBearerAuthRoundTripper(cfg.BearerToken, rt)
+	} else if len(cfg.BearerTokenFile) > 0 {
+		rt, err = NewBearerAuthFileRoundTripper(cfg.BearerTokenFile, rt)
 		if err != nil {
-			return nil, fmt.Errorf("unable to read bearer token file %s: %s", cfg.BearerTokenFile, err)
+			return nil, err
 		}
-		bearerToken = strings.TrimSpace(string(b))
-	}
-
-	if len(bearerToken) > 0 {
-		rt = NewBearerAuthRoundTripper(bearerToken, rt)
 	}
 
 	if cfg.BasicAuth != nil {
-		rt = NewBasicAuthRoundTripper(cfg.BasicAuth.Username, cfg.BasicAuth.Password, rt)
+		rt, err = NewBasicAuthRoundTripper(cfg.BasicAuth.Username, cfg.BasicAuth.Password, cfg.BasicAuth.PasswordFile, rt)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Return a new client with the configured round tripper.
@@ -117,24 +127,125 @@ func (rt *bearerAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 	return rt.rt.RoundTrip(req)
 }
 
+type bearerAuthFileRoundTripper struct {
+	bearerFile string
+	rt         http.RoundTripper
+
+	mtx         sync.Mutex
+	modTime     time.Time
+	size        int64
+	bearerToken string
+}
+
+// NewBearerAuthFileRoundTripper adds the bearer token read from the provided file to a request
+// unless the authorization header has already been set. The file is only re-read when its
+// modification time or size changes, so that rotated tokens are picked up without a restart.
+func NewBearerAuthFileRoundTripper(bearerFile string, rt http.RoundTripper) (http.RoundTripper, error) {
+	frt := &bearerAuthFileRoundTripper{bearerFile: bearerFile, rt: rt}
+	if _, err := frt.token(); err != nil {
+		return nil, err
+	}
+	return frt, nil
+}
+
+// token returns the current bearer token, re-reading the file if it changed.
+func (rt *bearerAuthFileRoundTripper) token() (string, error) {
+	fi, err := os.Stat(rt.bearerFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read bearer token file %s: %s", rt.bearerFile, err)
+	}
+
+	rt.mtx.Lock()
+	defer rt.mtx.Unlock()
+
+	if rt.modTime.Equal(fi.ModTime()) && rt.size == fi.Size() {
+		return rt.bearerToken, nil
+	}
+	b, err := ioutil.ReadFile(rt.bearerFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read bearer token file %s: %s", rt.bearerFile, err)
+	}
+	rt.bearerToken = strings.TrimSpace(string(b))
+	rt.modTime = fi.ModTime()
+	rt.size = fi.Size()
+	return rt.bearerToken, nil
+}
+
+func (rt *bearerAuthFileRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Authorization")) == 0 {
+		bearerToken, err := rt.token()
+		if err != nil {
+			return nil, err
+		}
+		req = cloneRequest(req)
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	return rt.rt.RoundTrip(req)
+}
+
 type basicAuthRoundTripper struct {
-	username string
-	password string
-	rt       http.RoundTripper
+	username     string
+	password     string
+	passwordFile string
+	rt           http.RoundTripper
+
+	mtx          sync.Mutex
+	modTime      time.Time
+	size         int64
+	filePassword string
 }
 
 // NewBasicAuthRoundTripper will apply a BASIC auth authorization header to a request unless it has
-// already been set.
-func NewBasicAuthRoundTripper(username, password string, rt http.RoundTripper) http.RoundTripper {
-	return &basicAuthRoundTripper{username, password, rt}
+// already been set. If passwordFile is not empty, the password is read from it instead, and only
+// re-read when its modification time or size changes, as for NewBearerAuthFileRoundTripper.
+func NewBasicAuthRoundTripper(username, password, passwordFile string, rt http.RoundTripper) (http.RoundTripper, error) {
+	brt := &basicAuthRoundTripper{username: username, password: password, passwordFile: passwordFile, rt: rt}
+	if len(passwordFile) > 0 {
+		if _, err := brt.passwordFromFile(); err != nil {
+			return nil, err
+		}
+	}
+	return brt, nil
+}
+
+// passwordFromFile returns the current password in the password file,
+// re-reading it if it changed.
+func (rt *basicAuthRoundTripper) passwordFromFile() (string, error) {
+	fi, err := os.Stat(rt.passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read basic auth password file %s: %s", rt.passwordFile, err)
+	}
+
+	rt.mtx.Lock()
+	defer rt.mtx.Unlock()
+
+	if rt.modTime.Equal(fi.ModTime()) && rt.size == fi.Size() {
+		return rt.filePassword, nil
+	}
+	b, err := ioutil.ReadFile(rt.passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read basic auth password file %s: %s", rt.passwordFile, err)
+	}
+	rt.filePassword = strings.TrimSpace(string(b))
+	rt.modTime = fi.ModTime()
+	rt.size = fi.Size()
+	return rt.filePassword, nil
 }
 
 func (rt *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	if len(req.Header.Get("Authorization")) != 0 {
 		return rt.rt.RoundTrip(req)
 	}
+	password := rt.password
+	if len(rt.passwordFile) > 0 {
+		var err error
+		if password, err = rt.passwordFromFile(); err != nil {
+			return nil, err
+		}
+	}
 	req = cloneRequest(req)
-	req.SetBasicAuth(rt.username, rt.password)
+	req.SetBasicAuth(rt.username, password)
 	return rt.rt.RoundTrip(req)
 }
 
@@ -157,30 +268,168 @@ func NewTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
 	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
 
 	// If a CA cert is provided then let's read it in so we can validate the
-	// scrape target's certificate properly.
+	// scrape target's certificate properly. It is reloaded on handshakes
+	// when changed, like the client cert below, which takes verifying the
+	// server certificate over from crypto/tls.
 	if len(cfg.CAFile) > 0 {
-		caCertPool := x509.NewCertPool()
-		// Load CA cert.
-		caCert, err := ioutil.ReadFile(cfg.CAFile)
+		l := &caPoolLoader{caFile: cfg.CAFile}
+		caCertPool, err := l.pool()
 		if err != nil {
-			return nil, fmt.Errorf("unable to use specified CA cert %s: %s", cfg.CAFile, err)
+			return nil, err
 		}
-		caCertPool.AppendCertsFromPEM(caCert)
 		tlsConfig.RootCAs = caCertPool
+		if !cfg.InsecureSkipVerify {
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+				return l.verify(cs)
+			}
+		}
 	}
 
 	if len(cfg.ServerName) > 0 {
 		tlsConfig.ServerName = cfg.ServerName
 	}
+	if len(cfg.MinVersion) > 0 {
+		v, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %q, must be one of TLS10, TLS11, TLS12 & TLS13", cfg.MinVersion)
+		}
+		tlsConfig.MinVersion = v
+	}
+	for _, name := range cfg.CipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
 	// If a client cert & key is provided then configure TLS config accordingly.
+	// They are reloaded on handshakes when changed, so that rotated
+	// certificates are picked up without a restart.
 	if len(cfg.CertFile) > 0 && len(cfg.KeyFile) > 0 {
-		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("unable to use specified client cert (%s) & key (%s): %s", cfg.CertFile, cfg.KeyFile, err)
+		l := &keyPairLoader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+		if _, err := l.keyPair(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return l.keyPair()
 		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 	tlsConfig.BuildNameToCertificate()
 
 	return tlsConfig, nil
 }
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// cipherSuiteID returns the ID of the cipher suite with the given name, be it
+// secure or not.
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if cs.Name == name {
+			return cs.ID, true
+		}
+	}
+	return 0, false
+}
+
+// keyPairLoader loads a certificate and key pair from files, caching it until
+// the modification time or size of either file changes.
+type keyPairLoader struct {
+	certFile, keyFile string
+
+	mtx               sync.Mutex
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+	cert              *tls.Certificate
+}
+
+func (l *keyPairLoader) keyPair() (*tls.Certificate, error) {
+	certFi, err := os.Stat(l.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use specified client cert (%s) & key (%s): %s", l.certFile, l.keyFile, err)
+	}
+	keyFi, err := os.Stat(l.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use specified client cert (%s) & key (%s): %s", l.certFile, l.keyFile, err)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.cert != nil &&
+		l.certMod.Equal(certFi.ModTime()) && l.certSize == certFi.Size() &&
+		l.keyMod.Equal(keyFi.ModTime()) && l.keySize == keyFi.Size() {
+		return l.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use specified client cert (%s) & key (%s): %s", l.certFile, l.keyFile, err)
+	}
+	l.cert = &cert
+	l.certMod, l.certSize = certFi.ModTime(), certFi.Size()
+	l.keyMod, l.keySize = keyFi.ModTime(), keyFi.Size()
+	return l.cert, nil
+}
+
+// caPoolLoader loads a pool of CA certificates from a file, caching it until
+// the modification time or size of the file changes.
+type caPoolLoader struct {
+	caFile string
+
+	mtx    sync.Mutex
+	caMod  time.Time
+	caSize int64
+	caPool *x509.CertPool
+}
+
+func (l *caPoolLoader) pool() (*x509.CertPool, error) {
+	fi, err := os.Stat(l.caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use specified CA cert %s: %s", l.caFile, err)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.caPool != nil && l.caMod.Equal(fi.ModTime()) && l.caSize == fi.Size() {
+		return l.caPool, nil
+	}
+	caCert, err := ioutil.ReadFile(l.caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use specified CA cert %s: %s", l.caFile, err)
+	}
+	caPool := x509.NewCertPool()
+	caPool.AppendCertsFromPEM(caCert)
+	l.caPool = caPool
+	l.caMod, l.caSize = fi.ModTime(), fi.Size()
+	return l.caPool, nil
+}
+
+// verify verifies the certificate chain and name of the server of a
+// connection against the current CA pool, as crypto/tls does against
+// RootCAs.
+func (l *caPoolLoader) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+	roots, err := l.pool()
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err = cs.PeerCertificates[0].Verify(opts)
+	return err
+}